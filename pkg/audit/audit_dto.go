@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EntryResponseDTO defines the structure for an audit log entry in responses.
+type EntryResponseDTO struct {
+	ID             uint            `json:"id"`
+	ActorID        *uint           `json:"actor_id"`
+	ImpersonatorID *uint           `json:"impersonator_id,omitempty"`
+	Action         string          `json:"action"`
+	TargetType     string          `json:"target_type,omitempty"`
+	TargetID       *uint           `json:"target_id,omitempty"`
+	Details        json.RawMessage `json:"details,omitempty"`
+	IP             string          `json:"ip"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ListFilterDTO narrows the audit log listing. Zero values match everything.
+type ListFilterDTO struct {
+	Action   string
+	ActorID  uint
+	TargetID uint
+}
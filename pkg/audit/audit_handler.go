@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"log"
+	"strconv"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ActorFromContext identifies the authenticated user making the request,
+// and the admin behind an impersonation token. It must run after
+// middleware.Protected.
+func ActorFromContext(c *fiber.Ctx) Actor {
+	actor := Actor{IP: c.IP()}
+	if claims, ok := c.Locals("claims").(*auth.AccessClaims); ok {
+		actor.UserID, _ = claims.UserID()
+		actor.ImpersonatorID, _ = claims.ImpersonatorID()
+	}
+	return actor
+}
+
+// RecordImpersonation records every write request made with an impersonation
+// token once it has been handled, so support actions can be traced back to
+// the admin. Use it in front of the routes, before middleware.Protected runs.
+func RecordImpersonation(service Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return err
+		}
+		actor := ActorFromContext(c)
+		if actor.ImpersonatorID == 0 {
+			return err
+		}
+
+		details := map[string]interface{}{
+			"method": c.Method(),
+			"path":   c.Path(),
+			"status": c.Response().StatusCode(),
+		}
+		if recordErr := service.Record(actor, ActionImpersonatedChange, "", 0, details); recordErr != nil {
+			log.Printf("Failed to record impersonated request by user %d: %v", actor.ImpersonatorID, recordErr)
+		}
+		return err
+	}
+}
+
+// List godoc
+// @Summary      List audit log entries
+// @Description  Admin only. List audit log entries, newest first, optionally filtered by action, actor or target.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page       query     int     false  "Page number"     default(1)
+// @Param        limit      query     int     false  "Items per page"  default(20)
+// @Param        action     query     string  false  "Action, e.g. user.impersonate"
+// @Param        actor_id   query     int     false  "User who acted, directly or by impersonation"
+// @Param        target_id  query     int     false  "User acted upon"
+// @Success      200        {object}  dto.ResponseWrapper[dto.PaginatedResponse[EntryResponseDTO]]
+// @Failure      401        {object}  dto.ResponseWrapper[any]
+// @Failure      403        {object}  dto.ResponseWrapper[any]
+// @Failure      500        {object}  dto.ResponseWrapper[any]
+// @Router       /admin/audit-log [get]
+func (h *Handler) List(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	actorID, _ := strconv.ParseUint(c.Query("actor_id"), 10, 32)
+	targetID, _ := strconv.ParseUint(c.Query("target_id"), 10, 32)
+
+	filter := ListFilterDTO{
+		Action:   c.Query("action"),
+		ActorID:  uint(actorID),
+		TargetID: uint(targetID),
+	}
+
+	result, err := h.service.List(filter, page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve audit log", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(result, "Audit log retrieved successfully"))
+}
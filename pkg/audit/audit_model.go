@@ -0,0 +1,31 @@
+package audit
+
+import "time"
+
+// Actions recorded in the audit log.
+const (
+	ActionUserCreate         = "user.create"
+	ActionUserDisable        = "user.disable"
+	ActionUserEnable         = "user.enable"
+	ActionUserPasswordReset  = "user.password_reset"
+	ActionUserRolesSet       = "user.roles_set"
+	ActionUserUnlock         = "user.unlock"
+	ActionUserLogout         = "user.logout"
+	ActionUserImpersonate    = "user.impersonate"
+	ActionImpersonatedChange = "impersonation.request" // A write made with an impersonation token
+	ActionInvitationCreate   = "invitation.create"
+	ActionInvitationRevoke   = "invitation.revoke"
+)
+
+// Entry records one administrative or otherwise sensitive action.
+type Entry struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	ActorID        *uint     `gorm:"index" json:"actor_id"`        // Nil for actions taken by the system
+	ImpersonatorID *uint     `gorm:"index" json:"impersonator_id"` // The admin acting as ActorID, if any
+	Action         string    `gorm:"size:64;not null;index" json:"action"`
+	TargetType     string    `gorm:"size:32" json:"target_type"`
+	TargetID       *uint     `gorm:"index" json:"target_id"`
+	Details        string    `gorm:"type:text" json:"-"` // JSON object
+	IP             string    `gorm:"size:64" json:"ip"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
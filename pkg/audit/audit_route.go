@@ -0,0 +1,18 @@
+package audit
+
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupAuditRoutes(router fiber.Router, handler *Handler) {
+	router.Get("/admin/audit-log",
+		middleware.Protected(),
+		middleware.SessionOnly(),
+		middleware.NotImpersonating(),
+		middleware.RequireRole(auth.RoleAdmin),
+		handler.List,
+	)
+}
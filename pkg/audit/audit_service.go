@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"tasklybe/pkg/dto"
+
+	"gorm.io/gorm"
+)
+
+// Target types recorded with entries.
+const (
+	TargetUser       = "user"
+	TargetInvitation = "invitation"
+)
+
+// Actor identifies who performed an action. The zero Actor is the system.
+type Actor struct {
+	UserID         uint
+	ImpersonatorID uint // Set when an admin acts as UserID
+	IP             string
+}
+
+type Service interface {
+	Record(actor Actor, action, targetType string, targetID uint, details map[string]interface{}) error
+	List(filter ListFilterDTO, page, limit int) (*dto.PaginatedResponse[EntryResponseDTO], error)
+}
+
+type service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) Service {
+	return &service{db: db}
+}
+
+// Record appends an entry to the audit log. Entries are never updated or
+// deleted through the API.
+func (s *service) Record(actor Actor, action, targetType string, targetID uint, details map[string]interface{}) error {
+	entry := Entry{
+		ActorID:        optionalID(actor.UserID),
+		ImpersonatorID: optionalID(actor.ImpersonatorID),
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       optionalID(targetID),
+		IP:             actor.IP,
+	}
+	if len(details) > 0 {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(encoded)
+	}
+	return s.db.Create(&entry).Error
+}
+
+// RedactedValue replaces personal data removed from entries.
+const RedactedValue = "[deleted]"
+
+// AnonymizeUser removes a deleted user's personal data from the log while
+// keeping the entries: details values equal to one of their email addresses
+// are redacted, and so is the IP of every request they made themselves.
+// User IDs are kept, since they no longer lead to anyone.
+func AnonymizeUser(db *gorm.DB, userID uint, emails ...string) error {
+	if err := db.Model(&Entry{}).
+		Where("actor_id = ? AND impersonator_id IS NULL", userID).
+		Update("ip", "").Error; err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		if email == "" {
+			continue
+		}
+		var entries []Entry
+		if err := db.Where("LOWER(details) LIKE ?", "%"+strings.ToLower(email)+"%").Find(&entries).Error; err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			var details map[string]interface{}
+			if err := json.Unmarshal([]byte(entry.Details), &details); err != nil {
+				return err
+			}
+			for key, value := range details {
+				if text, ok := value.(string); ok && strings.EqualFold(text, email) {
+					details[key] = RedactedValue
+				}
+			}
+			encoded, err := json.Marshal(details)
+			if err != nil {
+				return err
+			}
+			if err := db.Model(&entry).Update("details", string(encoded)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForUser returns every entry in which the user acted, directly or while
+// impersonated, or was acted upon, oldest first. It backs the user's
+// personal data export.
+func ForUser(db *gorm.DB, userID uint) ([]EntryResponseDTO, error) {
+	var entries []Entry
+	if err := db.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", userID, TargetUser, userID).
+		Order("created_at, id").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	responseList := make([]EntryResponseDTO, 0, len(entries))
+	for _, entry := range entries {
+		responseList = append(responseList, toResponseDTO(&entry))
+	}
+	return responseList, nil
+}
+
+// List returns audit log entries matching the filter, newest first.
+func (s *service) List(filter ListFilterDTO, page, limit int) (*dto.PaginatedResponse[EntryResponseDTO], error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&Entry{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorID != 0 {
+		// Include what admins did while impersonating someone else
+		query = query.Where("actor_id = ? OR impersonator_id = ?", filter.ActorID, filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	responseList := make([]EntryResponseDTO, 0, len(entries))
+	for _, entry := range entries {
+		responseList = append(responseList, toResponseDTO(&entry))
+	}
+
+	result := dto.NewPaginatedResponse(responseList, total, page, limit)
+	return &result, nil
+}
+
+// toResponseDTO converts an Entry model to EntryResponseDTO.
+func toResponseDTO(entry *Entry) EntryResponseDTO {
+	response := EntryResponseDTO{
+		ID:             entry.ID,
+		ActorID:        entry.ActorID,
+		ImpersonatorID: entry.ImpersonatorID,
+		Action:         entry.Action,
+		TargetType:     entry.TargetType,
+		TargetID:       entry.TargetID,
+		IP:             entry.IP,
+		CreatedAt:      entry.CreatedAt,
+	}
+	if entry.Details != "" {
+		response.Details = json.RawMessage(entry.Details)
+	}
+	return response
+}
+
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttempt counts recent failed logins for one key, such as an account or
+// a client IP. It backs the database AttemptStore.
+type LoginAttempt struct {
+	Key          string     `gorm:"primarykey;size:191" json:"key"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// AttemptStore keeps failed-attempt counters. Implementations must be safe
+// for concurrent use; the database store lets several instances share state.
+type AttemptStore interface {
+	// Get returns the counter for key, or nil if there is none.
+	Get(key string) (*LoginAttempt, error)
+	// RecordFailure atomically applies update to the counter for key,
+	// creating it first if needed, and returns the result.
+	RecordFailure(key string, update func(attempt *LoginAttempt)) (*LoginAttempt, error)
+	// Reset deletes the counter for key.
+	Reset(key string) error
+}
+
+type memoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempt
+}
+
+// NewMemoryAttemptStore creates an AttemptStore local to this process.
+func NewMemoryAttemptStore() AttemptStore {
+	return &memoryAttemptStore{attempts: make(map[string]LoginAttempt)}
+}
+
+func (s *memoryAttemptStore) Get(key string) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *memoryAttemptStore) RecordFailure(key string, update func(attempt *LoginAttempt)) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = LoginAttempt{Key: key}
+	}
+	update(&attempt)
+	s.attempts[key] = attempt
+	return &attempt, nil
+}
+
+func (s *memoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	delete(s.attempts, key)
+	s.mu.Unlock()
+	return nil
+}
+
+type dbAttemptStore struct {
+	db *gorm.DB
+}
+
+// NewDBAttemptStore creates an AttemptStore backed by the database.
+func NewDBAttemptStore(db *gorm.DB) AttemptStore {
+	return &dbAttemptStore{db: db}
+}
+
+func (s *dbAttemptStore) Get(key string) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	if err := s.db.Where("key = ?", key).First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *dbAttemptStore) RecordFailure(key string, update func(attempt *LoginAttempt)) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+		// Lock the row so concurrent failures on other instances are counted
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&attempt).Error; err != nil {
+			return err
+		}
+		update(&attempt)
+		return tx.Save(&attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *dbAttemptStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
+
+// LockoutError is returned while a login is refused because of too many
+// failed attempts.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// LoginThrottle limits failed logins per account and per client IP. Once a
+// key reaches its threshold, each further failure locks it for twice as long
+// as the previous one, up to MaxLockout.
+type LoginThrottle struct {
+	store AttemptStore
+
+	AccountThreshold int           // Failures allowed per account before locking
+	IPThreshold      int           // Failures allowed per IP before locking
+	BaseLockout      time.Duration // First lockout duration
+	MaxLockout       time.Duration // Longest lockout duration
+	Window           time.Duration // Failures older than this are forgotten
+}
+
+// NewLoginThrottle creates a LoginThrottle with default limits.
+func NewLoginThrottle(store AttemptStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:            store,
+		AccountThreshold: 5,
+		IPThreshold:      20,
+		BaseLockout:      30 * time.Second,
+		MaxLockout:       time.Hour,
+		Window:           15 * time.Minute,
+	}
+}
+
+// NewEmailThrottle creates a LoginThrottle for requests that email an
+// address, such as password reset links. Every request counts as a
+// failure: three per address or ten per IP lock further requests out.
+func NewEmailThrottle(store AttemptStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:            store,
+		AccountThreshold: 3,
+		IPThreshold:      10,
+		BaseLockout:      15 * time.Minute,
+		MaxLockout:       24 * time.Hour,
+		Window:           time.Hour,
+	}
+}
+
+// AccountKey returns the counter key for an account.
+func AccountKey(account string) string {
+	return "account:" + account
+}
+
+// IPKey returns the counter key for a client IP.
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// EmailKey returns the counter key for emails sent to an address.
+func EmailKey(address string) string {
+	return "email:" + address
+}
+
+// EmailIPKey returns the counter key for emails requested from a client IP,
+// kept apart from its failed logins.
+func EmailIPKey(ip string) string {
+	return "email-ip:" + ip
+}
+
+// Check returns a LockoutError if any of the keys is currently locked.
+func (t *LoginThrottle) Check(keys ...string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempt, err := t.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if attempt != nil && attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			if wait := attempt.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Failure records a failed login for the account and IP keys. It returns a
+// LockoutError if this failure locked either of them.
+func (t *LoginThrottle) Failure(accountKey, ipKey string) error {
+	var retryAfter time.Duration
+	for _, limit := range []struct {
+		key       string
+		threshold int
+	}{{accountKey, t.AccountThreshold}, {ipKey, t.IPThreshold}} {
+		if limit.key == "" {
+			continue
+		}
+		attempt, err := t.store.RecordFailure(limit.key, func(attempt *LoginAttempt) {
+			t.applyFailure(attempt, limit.threshold)
+		})
+		if err != nil {
+			return err
+		}
+		if attempt.LockedUntil != nil {
+			if wait := time.Until(*attempt.LockedUntil); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Reset clears the counter for key, for example after a successful login
+// or when an administrator unlocks an account.
+func (t *LoginThrottle) Reset(key string) error {
+	return t.store.Reset(key)
+}
+
+func (t *LoginThrottle) applyFailure(attempt *LoginAttempt, threshold int) {
+	now := time.Now()
+	// The window runs from the last failure or the end of the last lockout
+	lastActive := attempt.LastFailedAt
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(lastActive) {
+		lastActive = *attempt.LockedUntil
+	}
+	if now.Sub(lastActive) > t.Window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+
+	if attempt.Failures < threshold {
+		attempt.LockedUntil = nil
+		return
+	}
+
+	lockout := t.BaseLockout
+	for i := threshold; i < attempt.Failures && lockout < t.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > t.MaxLockout {
+		lockout = t.MaxLockout
+	}
+	lockedUntil := now.Add(lockout)
+	attempt.LockedUntil = &lockedUntil
+}
+
+// AttemptStoreFromEnv returns the AttemptStore selected with
+// LOGIN_ATTEMPT_STORE: "database" (the default, shared between instances)
+// or "memory".
+func AttemptStoreFromEnv(db *gorm.DB) AttemptStore {
+	switch store := os.Getenv("LOGIN_ATTEMPT_STORE"); store {
+	case "memory":
+		return NewMemoryAttemptStore()
+	case "", "database":
+		return NewDBAttemptStore(db)
+	default:
+		log.Printf("Warning: unknown LOGIN_ATTEMPT_STORE %q, using database", store)
+		return NewDBAttemptStore(db)
+	}
+}
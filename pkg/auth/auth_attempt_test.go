@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// tolerance absorbs the time between building an attempt and applying a failure.
+const tolerance = time.Second
+
+func testThrottle(store AttemptStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:            store,
+		AccountThreshold: 3,
+		IPThreshold:      5,
+		BaseLockout:      30 * time.Second,
+		MaxLockout:       4 * time.Minute,
+		Window:           15 * time.Minute,
+	}
+}
+
+func TestApplyFailureLocksFromThresholdAndDoubles(t *testing.T) {
+	throttle := testThrottle(NewMemoryAttemptStore())
+
+	tests := []struct {
+		failures int           // Failures after this one
+		want     time.Duration // Zero for not locked
+	}{
+		{1, 0},
+		{2, 0},
+		{3, 30 * time.Second},
+		{4, time.Minute},
+		{5, 2 * time.Minute},
+		{6, 4 * time.Minute},
+		{7, 4 * time.Minute},
+		{50, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		now := time.Now()
+		attempt := LoginAttempt{Failures: tt.failures - 1, LastFailedAt: now.Add(-time.Second)}
+		throttle.applyFailure(&attempt, throttle.AccountThreshold)
+
+		if attempt.Failures != tt.failures {
+			t.Errorf("after failure %d: counted %d failures", tt.failures, attempt.Failures)
+		}
+		if tt.want == 0 {
+			if attempt.LockedUntil != nil {
+				t.Errorf("after failure %d: locked until %s, want unlocked", tt.failures, attempt.LockedUntil)
+			}
+			continue
+		}
+		if attempt.LockedUntil == nil {
+			t.Errorf("after failure %d: unlocked, want a %s lockout", tt.failures, tt.want)
+			continue
+		}
+		if got := attempt.LockedUntil.Sub(now); got < tt.want || got > tt.want+tolerance {
+			t.Errorf("after failure %d: locked for %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestApplyFailureWindow(t *testing.T) {
+	throttle := testThrottle(NewMemoryAttemptStore())
+	now := time.Now()
+	at := func(ago time.Duration) *time.Time {
+		when := now.Add(-ago)
+		return &when
+	}
+
+	tests := []struct {
+		name         string
+		lastFailedAt time.Time
+		lockedUntil  *time.Time
+		want         int // Failures counted after this one
+	}{
+		{"recent failure", now.Add(-time.Minute), nil, 3},
+		{"failure outside the window", now.Add(-20 * time.Minute), nil, 1},
+		{"window runs from the end of the lockout", now.Add(-20 * time.Minute), at(10 * time.Minute), 3},
+		{"lockout ended outside the window", now.Add(-40 * time.Minute), at(16 * time.Minute), 1},
+		{"still locked", now.Add(-time.Minute), at(-time.Minute), 3},
+	}
+	for _, tt := range tests {
+		attempt := LoginAttempt{Failures: 2, LastFailedAt: tt.lastFailedAt, LockedUntil: tt.lockedUntil}
+		throttle.applyFailure(&attempt, 10)
+		if attempt.Failures != tt.want {
+			t.Errorf("%s: counted %d failures, want %d", tt.name, attempt.Failures, tt.want)
+		}
+	}
+}
+
+func TestCheckReturnsLongestLockout(t *testing.T) {
+	store := NewMemoryAttemptStore()
+	throttle := testThrottle(store)
+	now := time.Now()
+	lock := func(key string, until time.Time) {
+		if _, err := store.RecordFailure(key, func(attempt *LoginAttempt) {
+			attempt.LockedUntil = &until
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lock("short", now.Add(time.Minute))
+	lock("long", now.Add(5*time.Minute))
+	lock("expired", now.Add(-time.Minute))
+
+	tests := []struct {
+		keys []string
+		want time.Duration // Zero for no lockout
+	}{
+		{[]string{"short", "long", "expired", "unknown"}, 5 * time.Minute},
+		{[]string{"short", "expired"}, time.Minute},
+		{[]string{"expired", "unknown"}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		err := throttle.Check(tt.keys...)
+		var lockout *LockoutError
+		if tt.want == 0 {
+			if err != nil {
+				t.Errorf("Check(%v) = %v, want nil", tt.keys, err)
+			}
+			continue
+		}
+		if !errors.As(err, &lockout) {
+			t.Errorf("Check(%v) = %v, want a lockout", tt.keys, err)
+			continue
+		}
+		if lockout.RetryAfter > tt.want || lockout.RetryAfter < tt.want-tolerance {
+			t.Errorf("Check(%v) retry after %s, want %s", tt.keys, lockout.RetryAfter, tt.want)
+		}
+	}
+}
+
+func TestFailureUsesThresholdPerKey(t *testing.T) {
+	throttle := testThrottle(NewMemoryAttemptStore())
+	account, ip := AccountKey("1"), IPKey("203.0.113.7")
+
+	for i := 1; i < throttle.AccountThreshold; i++ {
+		if err := throttle.Failure(account, ip); err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+	}
+	var lockout *LockoutError
+	if err := throttle.Failure(account, ip); !errors.As(err, &lockout) {
+		t.Fatalf("got %v, want the account locked", err)
+	}
+	if err := throttle.Check(ip); err != nil {
+		t.Fatalf("IP locked below its threshold: %v", err)
+	}
+
+	if err := throttle.Reset(account); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Check(account, ip); err != nil {
+		t.Fatalf("still locked after reset: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Email verification modes, selected with EMAIL_VERIFICATION.
+const (
+	// VerificationOff lets unverified users do everything (the default).
+	VerificationOff = "off"
+	// VerificationLogin refuses to log in unverified users.
+	VerificationLogin = "login"
+	// VerificationWrite lets unverified users log in and read, but not write.
+	VerificationWrite = "write"
+)
+
+// EmailVerificationMode returns the configured email verification mode.
+// In the domain registration mode it is always VerificationLogin, since the
+// allow-list means nothing until users prove they own the address.
+func EmailVerificationMode() string {
+	if RegistrationMode() == RegistrationDomain {
+		return VerificationLogin
+	}
+
+	mode := os.Getenv("EMAIL_VERIFICATION")
+	switch mode {
+	case VerificationOff, VerificationLogin, VerificationWrite:
+		return mode
+	case "":
+		return VerificationOff
+	default:
+		log.Printf("Warning: unknown EMAIL_VERIFICATION %q, verification is not enforced", mode)
+		return VerificationOff
+	}
+}
+
+// Registration modes, selected with REGISTRATION_MODE.
+const (
+	// RegistrationOpen lets anyone register (the default).
+	RegistrationOpen = "open"
+	// RegistrationInvite requires an invitation code.
+	RegistrationInvite = "invite"
+	// RegistrationDomain lets addresses in REGISTRATION_DOMAINS register;
+	// anyone else needs an invitation code.
+	RegistrationDomain = "domain"
+)
+
+// RegistrationMode returns the configured registration mode.
+func RegistrationMode() string {
+	mode := os.Getenv("REGISTRATION_MODE")
+	switch mode {
+	case RegistrationOpen, RegistrationInvite, RegistrationDomain:
+		return mode
+	case "":
+		return RegistrationOpen
+	default:
+		// Fail closed: a typo should not open registration to everyone
+		log.Printf("Warning: unknown REGISTRATION_MODE %q, requiring invitations", mode)
+		return RegistrationInvite
+	}
+}
+
+// RegistrationDomains returns the lowercased email domains allowed to
+// register in domain mode, from the comma-separated REGISTRATION_DOMAINS.
+func RegistrationDomains() []string {
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv("REGISTRATION_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported for access tokens.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256" // Legacy JWT_SECRET only; not published in the JWKS
+)
+
+// legacyKeyID identifies the JWT_SECRET key, including in tokens signed
+// before key IDs were introduced.
+const legacyKeyID = "default"
+
+// SigningKeyConfig describes one key in JWT_KEYS.
+type SigningKeyConfig struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`              // RS256 or EdDSA
+	PrivateKey     string     `json:"private_key"`      // PEM; or use PrivateKeyFile
+	PrivateKeyFile string     `json:"private_key_file"` // Path to a PEM file
+	ActiveFrom     *time.Time `json:"active_from"`      // Signs new tokens from this time; verifies immediately
+	RetireAt       *time.Time `json:"retire_at"`        // Removed from verification and the JWKS after this time
+}
+
+// SigningKey is a loaded key used to sign or verify access tokens.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	RetireAt   *time.Time
+	VerifyOnly bool // Never signs new tokens, e.g. JWT_SECRET once JWT_KEYS is set
+
+	signingKey   interface{} // Private key for asymmetric keys, []byte for HS256
+	verification interface{} // Public key for asymmetric keys, []byte for HS256
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k *SigningKey) retired(now time.Time) bool {
+	return k.RetireAt != nil && now.After(*k.RetireAt)
+}
+
+// KeySet holds every key that may sign or verify access tokens. Rotation
+// follows each key's schedule: a new key is published in the JWKS as soon as
+// it is configured, signs tokens from its ActiveFrom time, and older keys
+// keep verifying until their RetireAt time. Set RetireAt at least one
+// access token lifetime after the successor becomes active.
+type KeySet struct {
+	keys []*SigningKey // Sorted by ActiveFrom, oldest first
+}
+
+// NewKeySet creates a KeySet from loaded keys.
+func NewKeySet(keys []*SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	return &KeySet{keys: sorted}, nil
+}
+
+// Current returns the key that signs new tokens: the most recently
+// activated key that has not been retired.
+func (s *KeySet) Current() (*SigningKey, error) {
+	now := time.Now()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		key := s.keys[i]
+		if !key.VerifyOnly && !key.ActiveFrom.After(now) && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("no active signing key")
+}
+
+// Lookup returns the unretired key with the given ID.
+func (s *KeySet) Lookup(kid string) (*SigningKey, error) {
+	now := time.Now()
+	for _, key := range s.keys {
+		if key.ID == kid && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys that may verify tokens, for other services.
+// Symmetric keys are never published.
+func (s *KeySet) JWKS() []JWK {
+	now := time.Now()
+	jwks := make([]JWK, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.retired(now) {
+			continue
+		}
+		switch public := key.verification.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: AlgRS256,
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: AlgEdDSA,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return jwks
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   *KeySet
+)
+
+// UseKeySet sets the keys used by NewAccessToken and ParseAccessToken.
+func UseKeySet(keys *KeySet) {
+	keySetMu.Lock()
+	keySet = keys
+	keySetMu.Unlock()
+}
+
+func currentKeySet() (*KeySet, error) {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	if keySet == nil {
+		return nil, errors.New("no signing keys configured")
+	}
+	return keySet, nil
+}
+
+// LoadKeySetFromEnv loads the keys described by JWT_KEYS, a JSON array of
+// SigningKeyConfig. Without JWT_KEYS it falls back to an HS256 key from
+// JWT_SECRET. It fails if neither is set, or if no key can sign tokens right
+// now, so the server never starts with an empty secret or unable to log
+// anyone in.
+//
+// When both are set, JWT_SECRET only verifies tokens, so tokens signed
+// before moving to JWT_KEYS stay valid. It is retired at
+// JWT_SECRET_RETIRE_AT (RFC 3339) if given, which should be at least one
+// access token lifetime after the switch.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	legacy, err := legacyKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*SigningKey
+	if raw := os.Getenv("JWT_KEYS"); raw != "" {
+		var configs []SigningKeyConfig
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			return nil, fmt.Errorf("invalid JWT_KEYS: %w", err)
+		}
+
+		for _, config := range configs {
+			key, err := LoadSigningKey(config)
+			if err != nil {
+				return nil, fmt.Errorf("JWT_KEYS key %q: %w", config.ID, err)
+			}
+			keys = append(keys, key)
+		}
+		if legacy != nil {
+			legacy.VerifyOnly = true
+			keys = append(keys, legacy)
+		}
+	} else if legacy != nil {
+		keys = []*SigningKey{legacy}
+	} else {
+		return nil, errors.New("neither JWT_KEYS nor JWT_SECRET is set")
+	}
+
+	set, err := NewKeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+	// E.g. every JWT_KEYS key activates in the future, or all are retired
+	if _, err := set.Current(); err != nil {
+		return nil, fmt.Errorf("no key can sign tokens now: %w", err)
+	}
+	return set, nil
+}
+
+// legacyKeyFromEnv returns the HS256 key from JWT_SECRET, or nil if unset.
+func legacyKeyFromEnv() (*SigningKey, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+
+	key := &SigningKey{
+		ID:           legacyKeyID,
+		Algorithm:    AlgHS256,
+		signingKey:   []byte(secret),
+		verification: []byte(secret),
+	}
+	if raw := os.Getenv("JWT_SECRET_RETIRE_AT"); raw != "" {
+		retireAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_SECRET_RETIRE_AT: %w", err)
+		}
+		key.RetireAt = &retireAt
+	}
+	return key, nil
+}
+
+// LoadSigningKey parses the private key of a SigningKeyConfig.
+func LoadSigningKey(config SigningKeyConfig) (*SigningKey, error) {
+	if config.ID == "" {
+		return nil, errors.New("kid is required")
+	}
+
+	pemData := []byte(config.PrivateKey)
+	if config.PrivateKeyFile != "" {
+		data, err := os.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pemData = data
+	}
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return nil, fmt.Errorf("unsupported private key: %w", err)
+		}
+		private = rsaKey
+	}
+
+	key := &SigningKey{ID: config.ID, Algorithm: config.Algorithm, RetireAt: config.RetireAt}
+	if config.ActiveFrom != nil {
+		key.ActiveFrom = *config.ActiveFrom
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if key.Algorithm != AlgRS256 {
+			return nil, fmt.Errorf("RSA key requires alg %s", AlgRS256)
+		}
+		if private.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		key.signingKey, key.verification = private, &private.PublicKey
+	case ed25519.PrivateKey:
+		if key.Algorithm != AlgEdDSA {
+			return nil, fmt.Errorf("Ed25519 key requires alg %s", AlgEdDSA)
+		}
+		key.signingKey, key.verification = private, private.Public()
+	default:
+		return nil, errors.New("only RSA and Ed25519 private keys are supported")
+	}
+	return key, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+// ed25519PEM returns a new Ed25519 private key in PKCS #8 PEM form.
+func ed25519PEM(t *testing.T) string {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestLoadKeySetFromEnvRequiresSigningKey(t *testing.T) {
+	tomorrow := time.Now().Add(24 * time.Hour)
+	yesterday := time.Now().Add(-24 * time.Hour)
+	keys := func(activeFrom *time.Time) string {
+		raw, err := json.Marshal([]SigningKeyConfig{{
+			ID:         "2026-10",
+			Algorithm:  AlgEdDSA,
+			PrivateKey: ed25519PEM(t),
+			ActiveFrom: activeFrom,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+
+	tests := []struct {
+		name     string
+		keys     string
+		secret   string
+		retireAt string
+		wantErr  bool
+	}{
+		{"secret only", "", "test-secret", "", false},
+		{"active key", keys(&yesterday), "test-secret", "", false},
+		{"key not active yet", keys(&tomorrow), "test-secret", "", true},
+		{"retired secret", "", "test-secret", yesterday.Format(time.RFC3339), true},
+		{"nothing set", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_KEYS", tt.keys)
+			t.Setenv("JWT_SECRET", tt.secret)
+			t.Setenv("JWT_SECRET_RETIRE_AT", tt.retireAt)
+
+			set, err := LoadKeySetFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("loaded a key set that cannot sign")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := set.Current(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
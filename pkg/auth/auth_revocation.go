@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultCacheTTL bounds how long an instance may miss a revocation made by
+// another instance. Revocations made locally are visible immediately.
+const defaultCacheTTL = 30 * time.Second
+
+// RevokedToken represents a single access token revoked before its expiry,
+// or every token of a login session when JTI has the form "sid:<id>".
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey;size:64" json:"jti"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"` // The row can be purged after this
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRevocation invalidates every access token issued to a user before RevokedBefore.
+type UserRevocation struct {
+	UserID        uint      `gorm:"primarykey" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null" json:"revoked_before"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RevocationStore records revoked access tokens and answers whether a token
+// may still be used.
+type RevocationStore interface {
+	// RevokeToken revokes a single token until it expires.
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	// RevokeSession revokes every token carrying the session ID until expiresAt,
+	// the latest expiry of a token issued to the session.
+	RevokeSession(sessionID string, userID uint, expiresAt time.Time) error
+	// RevokeAllForUser revokes every token issued to the user before now.
+	RevokeAllForUser(userID uint) error
+	// IsRevoked reports whether the token with the given claims was revoked.
+	IsRevoked(claims *AccessClaims) (bool, error)
+	// PurgeExpired deletes revocations of tokens that have expired anyway,
+	// and forgets cached answers that are too old to be used. Call it
+	// periodically, since every checked token adds to the cache.
+	PurgeExpired() error
+}
+
+type cachedCutoff struct {
+	revokedBefore time.Time // Zero when the user has no revocation
+	fetchedAt     time.Time
+}
+
+type cachedLookup struct {
+	revoked   bool
+	fetchedAt time.Time
+}
+
+type revocationStore struct {
+	db       *gorm.DB
+	cacheTTL time.Duration
+
+	mu      sync.RWMutex
+	tokens  map[string]cachedLookup
+	cutoffs map[uint]cachedCutoff
+}
+
+// NewRevocationStore creates a RevocationStore backed by the database with an
+// in-memory cache in front of it.
+func NewRevocationStore(db *gorm.DB) RevocationStore {
+	return &revocationStore{
+		db:       db,
+		cacheTTL: defaultCacheTTL,
+		tokens:   make(map[string]cachedLookup),
+		cutoffs:  make(map[uint]cachedCutoff),
+	}
+}
+
+func (s *revocationStore) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	record := RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}
+	if err := s.db.Where(RevokedToken{JTI: jti}).FirstOrCreate(&record).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = cachedLookup{revoked: true, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *revocationStore) RevokeSession(sessionID string, userID uint, expiresAt time.Time) error {
+	return s.RevokeToken(sessionRevocationKey(sessionID), userID, expiresAt)
+}
+
+// sessionRevocationKey returns the RevokedToken key for a session, which
+// cannot collide with a hex jti.
+func sessionRevocationKey(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+func (s *revocationStore) RevokeAllForUser(userID uint) error {
+	// Token timestamps have second precision, so the cutoff does too
+	now := time.Now().Truncate(time.Second)
+	record := UserRevocation{UserID: userID, RevokedBefore: now}
+	if err := s.db.Save(&record).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cutoffs[userID] = cachedCutoff{revokedBefore: now, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *revocationStore) IsRevoked(claims *AccessClaims) (bool, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return true, err
+	}
+
+	revokedBefore, err := s.userCutoff(userID)
+	if err != nil {
+		return true, err
+	}
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revokedBefore) {
+		return true, nil
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := s.tokenRevoked(sessionRevocationKey(claims.SessionID))
+		if err != nil || revoked {
+			return true, err
+		}
+	}
+
+	if claims.ID == "" {
+		return false, nil
+	}
+	return s.tokenRevoked(claims.ID)
+}
+
+func (s *revocationStore) PurgeExpired() error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, entry := range s.tokens {
+		if time.Since(entry.fetchedAt) > s.cacheTTL {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, entry := range s.cutoffs {
+		if time.Since(entry.fetchedAt) > s.cacheTTL {
+			delete(s.cutoffs, userID)
+		}
+	}
+	return nil
+}
+
+// tokenRevoked looks up a jti, caching the answer. Positive answers never
+// change, so only negative answers expire from the cache.
+func (s *revocationStore) tokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	entry, ok := s.tokens[jti]
+	s.mu.RUnlock()
+	if ok && (entry.revoked || time.Since(entry.fetchedAt) < s.cacheTTL) {
+		return entry.revoked, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return true, err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = cachedLookup{revoked: count > 0, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return count > 0, nil
+}
+
+// userCutoff returns the user's revocation cutoff, caching the answer.
+func (s *revocationStore) userCutoff(userID uint) (time.Time, error) {
+	s.mu.RLock()
+	entry, ok := s.cutoffs[userID]
+	s.mu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < s.cacheTTL {
+		return entry.revokedBefore, nil
+	}
+
+	var record UserRevocation
+	err := s.db.First(&record, userID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, err
+	}
+
+	s.mu.Lock()
+	s.cutoffs[userID] = cachedCutoff{revokedBefore: record.RevokedBefore, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return record.RevokedBefore, nil
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&RevokedToken{}, &UserRevocation{}); err != nil {
+		t.Fatal(err)
+	}
+	store := NewRevocationStore(db).(*revocationStore)
+
+	if err := store.RevokeToken("expired", 1, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken("current", 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.tokenRevoked("never-revoked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.userCutoff(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Answers fetched within the cache TTL are kept
+	if err := store.PurgeExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.tokens) != 3 || len(store.cutoffs) != 1 {
+		t.Fatalf("cache has %d tokens and %d cutoffs, want 3 and 1", len(store.tokens), len(store.cutoffs))
+	}
+
+	store.cacheTTL = 0
+	if err := store.PurgeExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.tokens) != 0 || len(store.cutoffs) != 0 {
+		t.Fatalf("cache has %d tokens and %d cutoffs, want none", len(store.tokens), len(store.cutoffs))
+	}
+
+	var remaining []RevokedToken
+	if err := db.Find(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].JTI != "current" {
+		t.Fatalf("remaining revocations: %+v, want only current", remaining)
+	}
+	if revoked, err := store.tokenRevoked("current"); err != nil || !revoked {
+		t.Fatalf("current token revoked = %v, %v, want true", revoked, err)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+// Roles a user can hold.
+const (
+	RoleAdmin   = "admin"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+// Permissions granted by roles.
+const (
+	PermTasksRead   = "tasks:read"
+	PermTasksWrite  = "tasks:write"
+	PermSiswaRead   = "siswa:read"
+	PermSiswaWrite  = "siswa:write"
+	PermUsersManage = "users:manage"
+	PermUsersInvite = "users:invite"
+)
+
+// rolePermissions maps every role to the permissions it grants.
+var rolePermissions = map[string][]string{
+	RoleAdmin:   {PermTasksRead, PermTasksWrite, PermSiswaRead, PermSiswaWrite, PermUsersManage, PermUsersInvite},
+	RoleTeacher: {PermTasksRead, PermTasksWrite, PermSiswaRead, PermSiswaWrite, PermUsersInvite},
+	RoleStudent: {PermTasksRead, PermTasksWrite},
+}
+
+// APITokenPrefix starts every personal access token, which lets Protected
+// tell them apart from JWTs.
+const APITokenPrefix = "tkly_"
+
+// APITokenScopes lists the permissions that can be granted to API tokens.
+var APITokenScopes = []string{PermTasksRead, PermTasksWrite, PermSiswaRead, PermSiswaWrite}
+
+// Roles returns every known role.
+func Roles() []string {
+	return []string{RoleAdmin, RoleTeacher, RoleStudent}
+}
+
+// IsValidRole reports whether role is a known role.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted by a single role.
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// HasPermission reports whether any of the roles grants the permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Identity describes the user an access token is issued to.
+type Identity struct {
+	UserID        uint
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	SessionID     string // Empty for tokens not tied to a login session
+	// ImpersonatorID is the admin acting as the user, for impersonation tokens.
+	ImpersonatorID uint
+}
+
+// ActorClaim identifies who is actually using a token issued to another
+// user (RFC 8693 section 4.1).
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
+// AccessClaims are the claims carried by access tokens.
+type AccessClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+	SessionID     string   `json:"sid,omitempty"`
+	// Actor is set on impersonation tokens to the admin using them.
+	Actor *ActorClaim `json:"act,omitempty"`
+	// Scopes, when set, further restricts the permissions granted by Roles.
+	// It is only used for API tokens, which never travel as JWTs.
+	Scopes []string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// HasPermission reports whether the token's roles grant the permission and,
+// for scoped tokens, whether the permission is within the token's scopes.
+func (c *AccessClaims) HasPermission(permission string) bool {
+	if !HasPermission(c.Roles, permission) {
+		return false
+	}
+	if c.Scopes == nil {
+		return true
+	}
+	for _, scope := range c.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UserID returns the numeric user ID stored in the subject claim.
+func (c *AccessClaims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid subject claim")
+	}
+	return uint(id), nil
+}
+
+// ImpersonatorID returns the ID of the admin using an impersonation token.
+// It reports false for ordinary tokens.
+func (c *AccessClaims) ImpersonatorID() (uint, bool) {
+	if c.Actor == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(c.Actor.Subject, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// NewAccessToken signs an access token for the user with a fresh jti.
+func NewAccessToken(identity Identity, ttl time.Duration) (string, *AccessClaims, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &AccessClaims{
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Roles:         identity.Roles,
+		SessionID:     identity.SessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(int(identity.UserID)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if identity.ImpersonatorID != 0 {
+		claims.Actor = &ActorClaim{Subject: strconv.Itoa(int(identity.ImpersonatorID))}
+	}
+
+	keys, err := currentKeySet()
+	if err != nil {
+		return "", nil, err
+	}
+	key, err := keys.Current()
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.signingKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// ParseAccessToken verifies an access token's signature and expiry and
+// returns its claims. Revocation is checked separately by a RevocationStore.
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	keys, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Tokens signed before key IDs were introduced used JWT_SECRET
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+		key, err := keys.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		// Don't forget to validate the alg is what you expect:
+		if token.Method.Alg() != key.method().Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verification, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// newTokenID returns a random 128-bit hex identifier for the jti claim.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -24,4 +24,15 @@ func NewErrorResponse(message string, err any) ResponseWrapper[any] {
 		Message: message,
 		Error:   err,
 	}
-}
\ No newline at end of file
+}
+
+// NewErrorResponseWithData creates an error response that also carries data,
+// e.g. the current state of a record after a failed conditional update.
+func NewErrorResponseWithData[T any](data *T, message string, err any) ResponseWrapper[T] {
+	return ResponseWrapper[T]{
+		Success: false,
+		Message: message,
+		Data:    data,
+		Error:   err,
+	}
+}
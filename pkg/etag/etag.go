@@ -0,0 +1,37 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Format returns the strong ETag for a record version, e.g. "3" (quotes included).
+func Format(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// ParseIfMatch extracts the expected version from an If-Match header.
+// It returns nil when the header is empty or "*", meaning any version matches.
+// Only a single entity tag is supported.
+func ParseIfMatch(header string) (*uint, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	if strings.Contains(header, ",") {
+		return nil, errors.New("If-Match must contain a single ETag")
+	}
+	if strings.HasPrefix(header, "W/") {
+		return nil, errors.New("If-Match requires a strong ETag")
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 32)
+	if err != nil {
+		return nil, errors.New("If-Match is not a valid ETag")
+	}
+
+	v := uint(version)
+	return &v, nil
+}
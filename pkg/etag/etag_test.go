@@ -0,0 +1,56 @@
+package etag
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	if got := Format(3); got != `"3"` {
+		t.Errorf(`Format(3) = %s, want "3"`, got)
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    *uint // Nil for any version
+		wantErr bool
+	}{
+		{header: "", want: nil},
+		{header: "*", want: nil},
+		{header: " * ", want: nil},
+		{header: `"3"`, want: version(3)},
+		{header: ` "12" `, want: version(12)},
+		{header: Format(7), want: version(7)},
+		{header: `W/"3"`, wantErr: true},
+		{header: `"3", "4"`, wantErr: true},
+		{header: `"abc"`, wantErr: true},
+		{header: `"-1"`, wantErr: true},
+		{header: `"99999999999"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIfMatch(tt.header)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseIfMatch(%q) accepted the header", tt.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIfMatch(%q): %v", tt.header, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseIfMatch(%q) = %v, want %v", tt.header, deref(got), deref(tt.want))
+		}
+	}
+}
+
+func version(v uint) *uint {
+	return &v
+}
+
+func deref(v *uint) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
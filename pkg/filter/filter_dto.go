@@ -0,0 +1,19 @@
+package filter
+
+// CreateSavedFilterDTO defines the structure for creating a new saved filter.
+type CreateSavedFilterDTO struct {
+	Name           string `json:"name" validate:"required"`
+	Status         string `json:"status" validate:"omitempty,oneof=all pending completed"`
+	Search         string `json:"search"`
+	IncludeSnoozed bool   `json:"include_snoozed"`
+}
+
+// UpdateSavedFilterDTO defines the structure for updating a saved filter.
+// Pointers are used to distinguish between a field not being provided
+// and a field being cleared (e.g., Search: "").
+type UpdateSavedFilterDTO struct {
+	Name           *string `json:"name" validate:"omitempty,min=1"`
+	Status         *string `json:"status" validate:"omitempty,oneof=all pending completed"`
+	Search         *string `json:"search"`
+	IncludeSnoozed *bool   `json:"include_snoozed"`
+}
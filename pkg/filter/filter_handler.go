@@ -0,0 +1,206 @@
+package filter
+
+import (
+	"strconv"
+	"tasklybe/pkg/dto"
+	"tasklybe/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) getUserIDFromLocals(c *fiber.Ctx) (uint, error) {
+	id, ok := c.Locals("userId").(uint)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusUnauthorized, "Cannot parse user ID")
+	}
+	return id, nil
+}
+
+// Create godoc
+// @Summary      Create a saved filter
+// @Description  Save a named task query for the logged-in user
+// @Tags         Filter
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        filter  body      CreateSavedFilterDTO  true  "Saved filter data"
+// @Success      201     {object}  dto.ResponseWrapper[SavedFilter]
+// @Failure      400     {object}  dto.ResponseWrapper[any]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Router       /filters [post]
+func (h *Handler) Create(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req CreateSavedFilterDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	filter, err := h.service.Create(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to create filter", err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(filter, "Filter created successfully"))
+}
+
+// GetAll godoc
+// @Summary      Get all saved filters
+// @Description  Get all saved filters for the logged-in user
+// @Tags         Filter
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[[]SavedFilter]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /filters [get]
+func (h *Handler) GetAll(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	filters, err := h.service.GetAll(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve filters", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&filters, "Filters retrieved successfully"))
+}
+
+// GetByID godoc
+// @Summary      Get a saved filter
+// @Description  Get a single saved filter by its ID
+// @Tags         Filter
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Filter ID"
+// @Success      200  {object}  dto.ResponseWrapper[SavedFilter]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /filters/{id} [get]
+func (h *Handler) GetByID(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	filterID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid filter ID", nil))
+	}
+
+	filter, err := h.service.GetByID(userID, uint(filterID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Filter not found", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(filter, "Filter retrieved successfully"))
+}
+
+// Update godoc
+// @Summary      Update a saved filter
+// @Description  Update a saved filter by its ID
+// @Tags         Filter
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path      int                   true  "Filter ID"
+// @Param        filter  body      UpdateSavedFilterDTO  true  "Saved filter update data"
+// @Success      200     {object}  dto.ResponseWrapper[SavedFilter]
+// @Failure      400     {object}  dto.ResponseWrapper[any]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Failure      404     {object}  dto.ResponseWrapper[any]
+// @Router       /filters/{id} [put]
+func (h *Handler) Update(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	filterID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid filter ID", nil))
+	}
+
+	var req UpdateSavedFilterDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	filter, err := h.service.Update(userID, uint(filterID), req)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to update filter", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(filter, "Filter updated successfully"))
+}
+
+// Delete godoc
+// @Summary      Delete a saved filter
+// @Description  Delete a saved filter by its ID
+// @Tags         Filter
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Filter ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /filters/{id} [delete]
+func (h *Handler) Delete(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	filterID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid filter ID", nil))
+	}
+
+	if err := h.service.Delete(userID, uint(filterID)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to delete filter", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Filter deleted successfully"))
+}
+
+// Run godoc
+// @Summary      Run a saved filter
+// @Description  List the tasks matching a saved filter, exactly as GET /tasks would
+// @Tags         Filter
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Filter ID"
+// @Success      200  {object}  dto.ResponseWrapper[[]task.Task]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /filters/{id}/tasks [get]
+func (h *Handler) Run(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	filterID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid filter ID", nil))
+	}
+
+	tasks, err := h.service.Run(userID, uint(filterID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to run filter", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&tasks, "Tasks retrieved successfully"))
+}
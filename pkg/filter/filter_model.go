@@ -0,0 +1,30 @@
+package filter
+
+import (
+	"tasklybe/pkg/task"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavedFilter represents a named task query saved by a user.
+type SavedFilter struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	UserID         uint           `gorm:"not null;index;uniqueIndex:idx_saved_filter_user_name,where:deleted_at IS NULL" json:"user_id"`
+	Name           string         `gorm:"not null;uniqueIndex:idx_saved_filter_user_name,where:deleted_at IS NULL" json:"name"`
+	Status         string         `gorm:"not null;default:all" json:"status"` // all / pending / completed
+	Search         string         `json:"search"`
+	IncludeSnoozed bool           `gorm:"not null;default:false" json:"include_snoozed"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ToTaskFilter converts the saved query into the criteria used by GET /api/tasks.
+func (f *SavedFilter) ToTaskFilter() task.TaskFilter {
+	return task.TaskFilter{
+		Status:         f.Status,
+		Search:         f.Search,
+		IncludeSnoozed: f.IncludeSnoozed,
+	}
+}
@@ -0,0 +1,23 @@
+package filter
+
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupFilterRoutes(router fiber.Router, handler *Handler) {
+	filterGroup := router.Group("/filters",
+		middleware.Protected(),
+		middleware.RequireReadWrite(auth.PermTasksRead, auth.PermTasksWrite),
+		middleware.VerifiedEmail(),
+	)
+
+	filterGroup.Post("/", handler.Create)
+	filterGroup.Get("/", handler.GetAll)
+	filterGroup.Get("/:id", handler.GetByID)
+	filterGroup.Put("/:id", handler.Update)
+	filterGroup.Delete("/:id", handler.Delete)
+	filterGroup.Get("/:id/tasks", handler.Run)
+}
@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"errors"
+	"tasklybe/pkg/task"
+
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	Create(userID uint, req CreateSavedFilterDTO) (*SavedFilter, error)
+	GetAll(userID uint) ([]SavedFilter, error)
+	GetByID(userID, filterID uint) (*SavedFilter, error)
+	Update(userID, filterID uint, req UpdateSavedFilterDTO) (*SavedFilter, error)
+	Delete(userID, filterID uint) error
+	Run(userID, filterID uint) ([]task.Task, error)
+}
+
+type service struct {
+	db          *gorm.DB
+	taskService task.Service
+}
+
+// NewService creates a saved filter service. Filters are executed through
+// taskService so they behave exactly like GET /api/tasks.
+func NewService(db *gorm.DB, taskService task.Service) Service {
+	return &service{db: db, taskService: taskService}
+}
+
+// Create saves a new filter for the user.
+func (s *service) Create(userID uint, req CreateSavedFilterDTO) (*SavedFilter, error) {
+	if err := s.ensureNameAvailable(userID, req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	status := req.Status
+	if status == "" {
+		status = task.StatusAll
+	}
+
+	filter := SavedFilter{
+		UserID:         userID,
+		Name:           req.Name,
+		Status:         status,
+		Search:         req.Search,
+		IncludeSnoozed: req.IncludeSnoozed,
+	}
+	if err := s.db.Create(&filter).Error; err != nil {
+		return nil, nameTakenError(err)
+	}
+	return &filter, nil
+}
+
+// GetAll lists the user's saved filters by name.
+func (s *service) GetAll(userID uint) ([]SavedFilter, error) {
+	var filters []SavedFilter
+	if err := s.db.Where("user_id = ?", userID).Order("name ASC").Find(&filters).Error; err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// GetByID retrieves one of the user's saved filters.
+func (s *service) GetByID(userID, filterID uint) (*SavedFilter, error) {
+	var filter SavedFilter
+	if err := s.db.Where("id = ? AND user_id = ?", filterID, userID).First(&filter).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("filter not found")
+		}
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// Update changes the provided fields of a saved filter.
+func (s *service) Update(userID, filterID uint, req UpdateSavedFilterDTO) (*SavedFilter, error) {
+	filter, err := s.GetByID(userID, filterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && *req.Name != filter.Name {
+		if err := s.ensureNameAvailable(userID, *req.Name, filter.ID); err != nil {
+			return nil, err
+		}
+		filter.Name = *req.Name
+	}
+	if req.Status != nil {
+		filter.Status = *req.Status
+		if filter.Status == "" {
+			filter.Status = task.StatusAll
+		}
+	}
+	if req.Search != nil {
+		filter.Search = *req.Search
+	}
+	if req.IncludeSnoozed != nil {
+		filter.IncludeSnoozed = *req.IncludeSnoozed
+	}
+
+	if err := s.db.Save(filter).Error; err != nil {
+		return nil, nameTakenError(err)
+	}
+	return filter, nil
+}
+
+// Delete removes a saved filter.
+func (s *service) Delete(userID, filterID uint) error {
+	filter, err := s.GetByID(userID, filterID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(filter).Error
+}
+
+// Run lists the tasks matching a saved filter.
+func (s *service) Run(userID, filterID uint) ([]task.Task, error) {
+	filter, err := s.GetByID(userID, filterID)
+	if err != nil {
+		return nil, err
+	}
+	return s.taskService.GetAllTasks(userID, filter.ToTaskFilter())
+}
+
+var errNameTaken = errors.New("filter name already exists")
+
+// ensureNameAvailable checks that the user has no other filter with the same name.
+// The unique index still catches two requests racing past this check.
+func (s *service) ensureNameAvailable(userID uint, name string, exceptID uint) error {
+	var existing SavedFilter
+	err := s.db.Where("user_id = ? AND name = ? AND id != ?", userID, name, exceptID).First(&existing).Error
+	if err == nil {
+		return errNameTaken
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// nameTakenError reports a violation of the unique filter name index like
+// ensureNameAvailable does.
+func nameTakenError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errNameTaken
+	}
+	return err
+}
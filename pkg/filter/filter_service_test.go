@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&SavedFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db, nil).(*service)
+}
+
+func TestCreateRejectsDuplicateNameFromConcurrentRequest(t *testing.T) {
+	s := newTestService(t)
+
+	// Another request saves the same name after the availability check
+	raced := false
+	if err := s.db.Callback().Create().Before("gorm:create").Register("test:race_filter_name", func(db *gorm.DB) {
+		if db.Statement.Table == "saved_filters" && !raced {
+			raced = true
+			db.Session(&gorm.Session{NewDB: true}).Exec(
+				"INSERT INTO saved_filters (user_id, name, status, include_snoozed) VALUES (1, 'Overdue', 'all', false)")
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.Create(1, CreateSavedFilterDTO{Name: "Overdue"})
+	if !errors.Is(err, errNameTaken) {
+		t.Fatalf("got %v, want %v", err, errNameTaken)
+	}
+	if _, err := s.Create(2, CreateSavedFilterDTO{Name: "Overdue"}); err != nil {
+		t.Fatalf("another user's filter was refused: %v", err)
+	}
+}
+
+func TestDeletedFilterNameCanBeReused(t *testing.T) {
+	s := newTestService(t)
+
+	first, err := s.Create(1, CreateSavedFilterDTO{Name: "Overdue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(1, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(1, CreateSavedFilterDTO{Name: "Overdue"}); err != nil {
+		t.Fatalf("name of a deleted filter was refused: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"log"
+	"os"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewFromEnv creates the Mailer selected by MAIL_DRIVER:
+//   - "smtp" sends through SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM
+//   - "file" appends messages to MAIL_FILE (default "mail.log")
+//   - "log" (the default) writes messages to the application log
+func NewFromEnv() Mailer {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	case "file":
+		path := os.Getenv("MAIL_FILE")
+		if path == "" {
+			path = "mail.log"
+		}
+		return NewFileMailer(path)
+	case "", "log":
+		return NewLogMailer()
+	default:
+		log.Printf("Warning: unknown MAIL_DRIVER %q, logging emails instead", os.Getenv("MAIL_DRIVER"))
+		return NewLogMailer()
+	}
+}
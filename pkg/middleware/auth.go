@@ -1,15 +1,35 @@
 package middleware
 
 import (
-	"os"
-	"strconv"
 	"strings"
+	"tasklybe/pkg/auth"
 	"tasklybe/pkg/dto"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+// revocations is consulted by Protected when set with UseRevocationStore.
+var revocations auth.RevocationStore
+
+// UseRevocationStore makes Protected reject tokens revoked in the given store.
+func UseRevocationStore(store auth.RevocationStore) {
+	revocations = store
+}
+
+// APITokenAuthenticator resolves personal access tokens to claims.
+type APITokenAuthenticator interface {
+	AuthenticateAPIToken(token, ip string) (*auth.AccessClaims, error)
+}
+
+// apiTokens is consulted by Protected when set with UseAPITokenAuthenticator.
+var apiTokens APITokenAuthenticator
+
+// UseAPITokenAuthenticator makes Protected accept personal access tokens
+// (bearer tokens starting with auth.APITokenPrefix) alongside JWTs.
+func UseAPITokenAuthenticator(authenticator APITokenAuthenticator) {
+	apiTokens = authenticator
+}
+
 // Protected is a middleware function to protect routes that require authentication.
 func Protected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -23,31 +43,134 @@ func Protected() fiber.Handler {
 			return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Invalid authorization header format", nil))
 		}
 
-		tokenString := parts[1]
-		jwtSecret := os.Getenv("JWT_SECRET")
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Don't forget to validate the alg is what you expect:
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Unexpected signing method")
+		if strings.HasPrefix(parts[1], auth.APITokenPrefix) && apiTokens != nil {
+			claims, err := apiTokens.AuthenticateAPIToken(parts[1], c.IP())
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Invalid or expired API token", err.Error()))
 			}
-			return []byte(jwtSecret), nil
-		})
+			return setClaims(c, claims, true)
+		}
 
+		claims, err := auth.ParseAccessToken(parts[1])
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Invalid or expired token", err.Error()))
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Store user information in context
-			userIDStr := claims["sub"].(string)
-			userID, _ := strconv.ParseUint(userIDStr, 10, 32)
+		if revocations != nil {
+			revoked, err := revocations.IsRevoked(claims)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(dto.NewErrorResponse("Failed to verify token", err.Error()))
+			}
+			if revoked {
+				return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Token has been revoked", nil))
+			}
+		}
+
+		return setClaims(c, claims, false)
+	}
+}
 
-			c.Locals("userId", uint(userID))
-			c.Locals("email", claims["email"].(string))
+// setClaims stores the authenticated user's information in the context.
+func setClaims(c *fiber.Ctx, claims *auth.AccessClaims, viaAPIToken bool) error {
+	userID, _ := claims.UserID()
+	c.Locals("userId", userID)
+	c.Locals("email", claims.Email)
+	c.Locals("claims", claims)
+	c.Locals("apiToken", viaAPIToken)
+	return c.Next()
+}
+
+// SessionOnly rejects requests authenticated with an API token, for routes
+// that manage the account itself. It must run after Protected.
+func SessionOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if viaAPIToken, _ := c.Locals("apiToken").(bool); viaAPIToken {
+			return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("API tokens cannot be used for this endpoint", nil))
+		}
+		return c.Next()
+	}
+}
+
+// NotImpersonating rejects requests made with an impersonation token, for
+// routes that change credentials or administer other users. It must run
+// after Protected.
+func NotImpersonating() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims, ok := c.Locals("claims").(*auth.AccessClaims); ok && claims.Actor != nil {
+			return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Impersonation tokens cannot be used for this endpoint", nil))
+		}
+		return c.Next()
+	}
+}
+
+// RequireRole allows the request only if the user holds at least one of the
+// given roles. It must run after Protected.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("claims").(*auth.AccessClaims)
+		if ok {
+			for _, held := range claims.Roles {
+				for _, role := range roles {
+					if held == role {
+						return c.Next()
+					}
+				}
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Insufficient role", nil))
+	}
+}
+
+// RequirePermission allows the request only if the user's roles, and the
+// scopes of an API token, grant every one of the given permissions.
+// It must run after Protected.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("claims").(*auth.AccessClaims)
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Insufficient permissions", nil))
+		}
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Insufficient permissions", permission))
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireReadWrite requires the read permission for safe methods (GET, HEAD,
+// OPTIONS) and the write permission for everything else. It must run after Protected.
+func RequireReadWrite(read, write string) fiber.Handler {
+	readCheck := RequirePermission(read)
+	writeCheck := RequirePermission(write)
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return readCheck(c)
+		}
+		return writeCheck(c)
+	}
+}
+
+// VerifiedEmail rejects write requests from users whose email address is not
+// verified when EMAIL_VERIFICATION is "write". Reads are always allowed.
+// It must run after Protected.
+func VerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.EmailVerificationMode() != auth.VerificationWrite {
+			return c.Next()
+		}
+
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
 			return c.Next()
 		}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Invalid token claims", nil))
+		claims, ok := c.Locals("claims").(*auth.AccessClaims)
+		if !ok || !claims.EmailVerified {
+			return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Email address has not been verified", nil))
+		}
+		return c.Next()
 	}
 }
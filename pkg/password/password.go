@@ -0,0 +1,156 @@
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBytes is the longest password bcrypt can hash in full.
+const MaxBytes = 72
+
+// Rules a password can fail, reported as validation tags.
+const (
+	RuleLength   = "password_length"
+	RuleClasses  = "password_classes"
+	RulePersonal = "password_personal"
+	RuleCommon   = "password_common"
+)
+
+// ErrPersonal is returned when a password contains the user's own details.
+var ErrPersonal = errors.New("password must not contain your name, username or email address")
+
+// minPersonalLength ignores name parts too short to matter, like "Al".
+const minPersonalLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = parseCommonPasswords(commonPasswordList)
+
+// Policy describes what makes a password acceptable.
+type Policy struct {
+	MinLength    int  // Minimum length in characters
+	MinClasses   int  // Minimum number of lowercase, uppercase, digit and symbol classes used
+	RejectCommon bool // Reject passwords on the bundled list of common passwords
+}
+
+// PolicyFromEnv returns the policy configured with PASSWORD_MIN_LENGTH
+// (default 8), PASSWORD_MIN_CLASSES (default 2, at most 4) and
+// PASSWORD_REJECT_COMMON (default true).
+func PolicyFromEnv() Policy {
+	return Policy{
+		MinLength:    intFromEnv("PASSWORD_MIN_LENGTH", 8, 1, MaxBytes),
+		MinClasses:   intFromEnv("PASSWORD_MIN_CLASSES", 2, 1, 4),
+		RejectCommon: os.Getenv("PASSWORD_REJECT_COMMON") != "false",
+	}
+}
+
+// Check returns the rules the password fails, in the order users should fix
+// them. Personal details such as the user's name and email are optional.
+func (p Policy) Check(password string, personal ...string) []string {
+	var failed []string
+	if !p.LengthOK(password) {
+		failed = append(failed, RuleLength)
+	}
+	if Classes(password) < p.MinClasses {
+		failed = append(failed, RuleClasses)
+	}
+	if ContainsPersonal(password, personal...) {
+		failed = append(failed, RulePersonal)
+	}
+	if p.RejectCommon && IsCommon(password) {
+		failed = append(failed, RuleCommon)
+	}
+	return failed
+}
+
+// LengthOK reports whether the password is long enough, and short enough
+// for bcrypt.
+func (p Policy) LengthOK(password string) bool {
+	return utf8.RuneCountInString(password) >= p.MinLength && len(password) <= MaxBytes
+}
+
+// Classes counts the character classes in the password: lowercase letters,
+// uppercase letters, digits, and everything else.
+func Classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// ContainsPersonal reports whether the password contains, ignoring case,
+// any word of the given details. Email addresses are checked by the part
+// before the "@".
+func ContainsPersonal(password string, personal ...string) bool {
+	lowered := strings.ToLower(password)
+	for _, detail := range personal {
+		detail = strings.ToLower(detail)
+		if at := strings.LastIndex(detail, "@"); at >= 0 {
+			detail = detail[:at]
+		}
+		words := strings.FieldsFunc(detail, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range append(words, detail) {
+			if utf8.RuneCountInString(word) >= minPersonalLength && strings.Contains(lowered, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsCommon reports whether the password is on the bundled list of common
+// passwords, also after dropping trailing digits and symbols, so that
+// "Sunshine2024!" counts as "sunshine".
+func IsCommon(password string) bool {
+	lowered := strings.ToLower(password)
+	if commonPasswords[lowered] {
+		return true
+	}
+	base := strings.TrimRightFunc(lowered, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return base != "" && commonPasswords[base]
+}
+
+func parseCommonPasswords(list string) map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[line] = true
+		}
+	}
+	return passwords
+}
+
+func intFromEnv(key string, fallback, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
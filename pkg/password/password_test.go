@@ -0,0 +1,102 @@
+package password
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, MinClasses: 2, RejectCommon: true}
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     []string
+	}{
+		{"acceptable", "Kx7#mQ2vLp", nil, nil},
+		{"too short", "Kx7#m", nil, []string{RuleLength}},
+		{"too long for bcrypt", "Kx7#" + strings.Repeat("a", MaxBytes), nil, []string{RuleLength}},
+		{"length counts characters, not bytes", "Kéçàüöñ1", nil, nil},
+		{"one class", "kxmqvlpzrt", nil, []string{RuleClasses}},
+		{"common", "password", nil, []string{RuleClasses, RuleCommon}},
+		{"common with a suffix", "Sunshine2024!", nil, []string{RuleCommon}},
+		{"name", "Ikhsan#2024", []string{"Ikhsan Maulana", "ikhsan@example.com"}, []string{RulePersonal}},
+		{"every rule in order", "ikhsan", []string{"ikhsan"}, []string{RuleLength, RuleClasses, RulePersonal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Check(tt.password, tt.personal...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckAllowsCommonWhenDisabled(t *testing.T) {
+	policy := Policy{MinLength: 8, MinClasses: 1}
+	if got := policy.Check("password"); got != nil {
+		t.Errorf("Check(%q) = %v, want no failures", "password", got)
+	}
+}
+
+func TestIsCommon(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"123456", true},
+		{"password", true},
+		{"PassWord", true},
+		{"qwerty123", true},
+		{"Dragon99!", true},
+		{"sekolah123", true},
+		{"taskly", true},
+		{"Kx7#mQ2vLp", false},
+		{"correct horse battery staple", false},
+		{"", false},
+		{"!!!", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommon(tt.password); got != tt.want {
+			t.Errorf("IsCommon(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestCommonPasswordListSize(t *testing.T) {
+	if len(commonPasswords) < 7000 {
+		t.Errorf("bundled list has %d passwords, want at least 7000", len(commonPasswords))
+	}
+	for password := range commonPasswords {
+		if password != strings.ToLower(password) {
+			t.Errorf("bundled password %q is not lowercase", password)
+		}
+	}
+}
+
+func TestContainsPersonal(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     bool
+	}{
+		{"no details", "Ikhsan#2024", nil, false},
+		{"full name", "ikhsanmaulana1", []string{"Ikhsan Maulana"}, true},
+		{"one word of the name, any case", "xxMAULANAxx", []string{"Ikhsan Maulana"}, true},
+		{"email local part", "mr.ikhsan!", []string{"mr.ikhsan@example.com"}, true},
+		{"email word", "Ikhsan#2024", []string{"mr.ikhsan@example.com"}, true},
+		{"email domain is ignored", "example#2024", []string{"ikhsan@example.com"}, false},
+		{"short words are ignored", "Al#2024xyz", []string{"Al Wong"}, false},
+		{"unrelated", "Kx7#mQ2vLp", []string{"Ikhsan Maulana", "ikhsan@example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainsPersonal(tt.password, tt.personal...); got != tt.want {
+				t.Errorf("ContainsPersonal(%q, %q) = %v, want %v", tt.password, tt.personal, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package planner
+
+// AddPlanItemDTO defines the structure for adding a task to a day's plan.
+type AddPlanItemDTO struct {
+	TaskID uint   `json:"task_id" validate:"required"`
+	Date   string `json:"date" validate:"omitempty,datetime=2006-01-02"` // Defaults to today
+}
+
+// ReorderPlanDTO defines the structure for reordering a day's plan.
+// TaskIDs must list every task currently planned for the day, in the new order.
+type ReorderPlanDTO struct {
+	Date    string `json:"date" validate:"omitempty,datetime=2006-01-02"` // Defaults to today
+	TaskIDs []uint `json:"task_ids" validate:"required"`
+}
+
+// PlanResponseDTO defines the structure for a day's plan in responses.
+type PlanResponseDTO struct {
+	Date  string     `json:"date"`
+	Items []PlanItem `json:"items"`
+}
+
+// WeekPlanResponseDTO defines the structure for a week of plans in responses.
+type WeekPlanResponseDTO struct {
+	StartDate string            `json:"start_date"`
+	Days      []PlanResponseDTO `json:"days"` // Seven days from StartDate
+}
@@ -0,0 +1,168 @@
+package planner
+
+import (
+	"strconv"
+	"tasklybe/pkg/dto"
+	"tasklybe/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) getUserIDFromLocals(c *fiber.Ctx) (uint, error) {
+	id, ok := c.Locals("userId").(uint)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusUnauthorized, "Cannot parse user ID")
+	}
+	return id, nil
+}
+
+// GetPlan godoc
+// @Summary      Get a day's plan
+// @Description  List the tasks the logged-in user planned for a day, in order
+// @Tags         Planner
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        date  query     string  false  "Day (YYYY-MM-DD), defaults to today"
+// @Success      200   {object}  dto.ResponseWrapper[PlanResponseDTO]
+// @Failure      400   {object}  dto.ResponseWrapper[any]
+// @Failure      401   {object}  dto.ResponseWrapper[any]
+// @Router       /my-day [get]
+func (h *Handler) GetPlan(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	plan, err := h.service.GetPlan(userID, c.Query("date", ""))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to retrieve plan", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(plan, "Plan retrieved successfully"))
+}
+
+// GetWeek godoc
+// @Summary      Get a week's plans
+// @Description  List the tasks the logged-in user planned for each day of a week, starting on the week start in their preferences
+// @Tags         Planner
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        date  query     string  false  "Any day of the week (YYYY-MM-DD), defaults to today"
+// @Success      200   {object}  dto.ResponseWrapper[WeekPlanResponseDTO]
+// @Failure      400   {object}  dto.ResponseWrapper[any]
+// @Failure      401   {object}  dto.ResponseWrapper[any]
+// @Router       /my-day/week [get]
+func (h *Handler) GetWeek(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	week, err := h.service.GetWeek(userID, c.Query("date", ""))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to retrieve week", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(week, "Week retrieved successfully"))
+}
+
+// AddTask godoc
+// @Summary      Add a task to a day's plan
+// @Description  Append one of the logged-in user's tasks to a day's plan
+// @Tags         Planner
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        item  body      AddPlanItemDTO  true  "Plan item data"
+// @Success      201   {object}  dto.ResponseWrapper[PlanResponseDTO]
+// @Failure      400   {object}  dto.ResponseWrapper[any]
+// @Failure      401   {object}  dto.ResponseWrapper[any]
+// @Router       /my-day [post]
+func (h *Handler) AddTask(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req AddPlanItemDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	plan, err := h.service.AddTask(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to add task to plan", err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(plan, "Task added to plan successfully"))
+}
+
+// RemoveTask godoc
+// @Summary      Remove a task from a day's plan
+// @Description  Take a task off the logged-in user's plan for a day
+// @Tags         Planner
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        taskId  path      int     true   "Task ID"
+// @Param        date    query     string  false  "Day (YYYY-MM-DD), defaults to today"
+// @Success      200     {object}  dto.ResponseWrapper[PlanResponseDTO]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Failure      404     {object}  dto.ResponseWrapper[any]
+// @Router       /my-day/{taskId} [delete]
+func (h *Handler) RemoveTask(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid task ID", nil))
+	}
+
+	plan, err := h.service.RemoveTask(userID, uint(taskID), c.Query("date", ""))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to remove task from plan", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(plan, "Task removed from plan successfully"))
+}
+
+// Reorder godoc
+// @Summary      Reorder a day's plan
+// @Description  Set the order of every task planned for a day
+// @Tags         Planner
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        order  body      ReorderPlanDTO  true  "New order"
+// @Success      200    {object}  dto.ResponseWrapper[PlanResponseDTO]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Router       /my-day/order [put]
+func (h *Handler) Reorder(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req ReorderPlanDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	plan, err := h.service.Reorder(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to reorder plan", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(plan, "Plan reordered successfully"))
+}
@@ -0,0 +1,18 @@
+package planner
+
+import (
+	"tasklybe/pkg/task"
+	"time"
+)
+
+// PlanItem represents a task a user picked for a given day.
+type PlanItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_plan_user_day_task" json:"user_id"`
+	Day       string    `gorm:"size:10;not null;uniqueIndex:idx_plan_user_day_task" json:"day"` // Format: YYYY-MM-DD
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_plan_user_day_task" json:"task_id"`
+	Task      task.Task `gorm:"foreignKey:TaskID" json:"task"`
+	Position  int       `gorm:"not null" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package planner
+
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupPlannerRoutes(router fiber.Router, handler *Handler) {
+	planGroup := router.Group("/my-day",
+		middleware.Protected(),
+		middleware.RequireReadWrite(auth.PermTasksRead, auth.PermTasksWrite),
+		middleware.VerifiedEmail(),
+	)
+
+	planGroup.Get("/", handler.GetPlan)
+	planGroup.Get("/week", handler.GetWeek)
+	planGroup.Post("/", handler.AddTask)
+	planGroup.Put("/order", handler.Reorder)
+	planGroup.Delete("/:taskId", handler.RemoveTask)
+}
@@ -0,0 +1,212 @@
+package planner
+
+import (
+	"errors"
+	"tasklybe/pkg/task"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dayFormat is the layout used for plan days.
+const dayFormat = "2006-01-02"
+
+type Service interface {
+	GetPlan(userID uint, day string) (*PlanResponseDTO, error)
+	GetWeek(userID uint, day string) (*WeekPlanResponseDTO, error)
+	AddTask(userID uint, req AddPlanItemDTO) (*PlanResponseDTO, error)
+	RemoveTask(userID, taskID uint, day string) (*PlanResponseDTO, error)
+	Reorder(userID uint, req ReorderPlanDTO) (*PlanResponseDTO, error)
+}
+
+// LocationResolver returns the time zone a user's days are counted in and
+// the day their weeks start on.
+type LocationResolver interface {
+	Location(userID uint) *time.Location
+	WeekStart(userID uint) time.Weekday
+}
+
+type service struct {
+	db        *gorm.DB
+	locations LocationResolver
+}
+
+func NewService(db *gorm.DB, locations LocationResolver) Service {
+	return &service{db: db, locations: locations}
+}
+
+// GetPlan lists the tasks planned for a day, in order.
+func (s *service) GetPlan(userID uint, day string) (*PlanResponseDTO, error) {
+	day, err := s.resolveDay(userID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []PlanItem
+	if err := s.db.Preload("Task").
+		Where("user_id = ? AND day = ?", userID, day).
+		Order("position ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	// Skip items whose task has since been deleted
+	planned := make([]PlanItem, 0, len(items))
+	for _, item := range items {
+		if item.Task.ID != 0 {
+			planned = append(planned, item)
+		}
+	}
+
+	return &PlanResponseDTO{Date: day, Items: planned}, nil
+}
+
+// GetWeek lists the plans for the seven days of the week containing day,
+// starting on the user's week start.
+func (s *service) GetWeek(userID uint, day string) (*WeekPlanResponseDTO, error) {
+	day, err := s.resolveDay(userID, day)
+	if err != nil {
+		return nil, err
+	}
+	date, _ := time.Parse(dayFormat, day)
+	offset := (int(date.Weekday()) - int(s.locations.WeekStart(userID)) + 7) % 7
+	start := date.AddDate(0, 0, -offset)
+
+	week := WeekPlanResponseDTO{StartDate: start.Format(dayFormat), Days: make([]PlanResponseDTO, 7)}
+	dayIndex := make(map[string]int, len(week.Days))
+	for i := range week.Days {
+		date := start.AddDate(0, 0, i).Format(dayFormat)
+		week.Days[i] = PlanResponseDTO{Date: date, Items: []PlanItem{}}
+		dayIndex[date] = i
+	}
+
+	var items []PlanItem
+	if err := s.db.Preload("Task").
+		Where("user_id = ? AND day BETWEEN ? AND ?", userID, week.Days[0].Date, week.Days[6].Date).
+		Order("day ASC, position ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		// Skip items whose task has since been deleted
+		if item.Task.ID != 0 {
+			i := dayIndex[item.Day]
+			week.Days[i].Items = append(week.Days[i].Items, item)
+		}
+	}
+	return &week, nil
+}
+
+// AddTask appends one of the user's tasks to a day's plan.
+func (s *service) AddTask(userID uint, req AddPlanItemDTO) (*PlanResponseDTO, error) {
+	day, err := s.resolveDay(userID, req.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	var t task.Task
+	if err := s.db.Where("id = ? AND user_id = ?", req.TaskID, userID).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("task not found")
+		}
+		return nil, err
+	}
+
+	var existing PlanItem
+	if err := s.db.Where("user_id = ? AND day = ? AND task_id = ?", userID, day, req.TaskID).First(&existing).Error; err == nil {
+		return nil, errors.New("task is already planned for this day")
+	}
+
+	var maxPosition int
+	if err := s.db.Model(&PlanItem{}).
+		Where("user_id = ? AND day = ?", userID, day).
+		Select("COALESCE(MAX(position), 0)").
+		Scan(&maxPosition).Error; err != nil {
+		return nil, err
+	}
+
+	item := PlanItem{
+		UserID:   userID,
+		Day:      day,
+		TaskID:   req.TaskID,
+		Position: maxPosition + 1,
+	}
+	if err := s.db.Create(&item).Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetPlan(userID, day)
+}
+
+// RemoveTask takes a task off a day's plan.
+func (s *service) RemoveTask(userID, taskID uint, day string) (*PlanResponseDTO, error) {
+	day, err := s.resolveDay(userID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.Where("user_id = ? AND day = ? AND task_id = ?", userID, day, taskID).Delete(&PlanItem{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("task is not planned for this day")
+	}
+
+	return s.GetPlan(userID, day)
+}
+
+// Reorder rewrites the positions of a day's plan in a single transaction.
+func (s *service) Reorder(userID uint, req ReorderPlanDTO) (*PlanResponseDTO, error) {
+	day, err := s.resolveDay(userID, req.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var items []PlanItem
+		if err := tx.Where("user_id = ? AND day = ?", userID, day).Find(&items).Error; err != nil {
+			return err
+		}
+
+		if len(items) != len(req.TaskIDs) {
+			return errors.New("task_ids must contain every planned task exactly once")
+		}
+
+		itemsByTask := make(map[uint]PlanItem, len(items))
+		for _, item := range items {
+			itemsByTask[item.TaskID] = item
+		}
+
+		for i, taskID := range req.TaskIDs {
+			item, ok := itemsByTask[taskID]
+			if !ok {
+				return errors.New("task_ids must contain every planned task exactly once")
+			}
+			delete(itemsByTask, taskID)
+
+			if err := tx.Model(&item).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPlan(userID, day)
+}
+
+// resolveDay validates a YYYY-MM-DD day, defaulting to today in the
+// user's time zone.
+func (s *service) resolveDay(userID uint, day string) (string, error) {
+	if day == "" {
+		return time.Now().In(s.locations.Location(userID)).Format(dayFormat), nil
+	}
+	if _, err := time.Parse(dayFormat, day); err != nil {
+		return "", errors.New("invalid date format, use YYYY-MM-DD")
+	}
+	return day, nil
+}
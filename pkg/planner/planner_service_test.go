@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"reflect"
+	"tasklybe/pkg/task"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fixedCalendar resolves every user to UTC and the same week start.
+type fixedCalendar struct {
+	weekStart time.Weekday
+}
+
+func (c fixedCalendar) Location(uint) *time.Location { return time.UTC }
+
+func (c fixedCalendar) WeekStart(uint) time.Weekday { return c.weekStart }
+
+func TestGetWeek(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&task.Task{}, &PlanItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2026-10-18 is a Sunday and 2026-10-19 a Monday
+	planned := task.Task{UserID: 1, Title: "Grade essays"}
+	if err := db.Create(&planned).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []string{"2026-10-18", "2026-10-19", "2026-10-25"} {
+		if err := db.Create(&PlanItem{UserID: 1, Day: day, TaskID: planned.ID, Position: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		weekStart time.Weekday
+		day       string
+		wantStart string
+		wantItems []string // Days with a planned task
+	}{
+		{"monday week", time.Monday, "2026-10-21", "2026-10-19", []string{"2026-10-19", "2026-10-25"}},
+		{"monday week from its last day", time.Monday, "2026-10-18", "2026-10-12", []string{"2026-10-18"}},
+		{"sunday week", time.Sunday, "2026-10-21", "2026-10-18", []string{"2026-10-18", "2026-10-19"}},
+		{"sunday week from its first day", time.Sunday, "2026-10-25", "2026-10-25", []string{"2026-10-25"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(db, fixedCalendar{weekStart: tt.weekStart})
+			week, err := s.GetWeek(1, tt.day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if week.StartDate != tt.wantStart || len(week.Days) != 7 || week.Days[0].Date != tt.wantStart {
+				t.Fatalf("got week from %s with %d days, want 7 days from %s", week.StartDate, len(week.Days), tt.wantStart)
+			}
+
+			var withItems []string
+			for _, day := range week.Days {
+				if len(day.Items) > 0 {
+					withItems = append(withItems, day.Date)
+				}
+			}
+			if !reflect.DeepEqual(withItems, tt.wantItems) {
+				t.Fatalf("got tasks on %v, want %v", withItems, tt.wantItems)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package search
+
+import "time"
+
+// ResultDTO defines the structure for a single search hit in responses.
+type ResultDTO struct {
+	Type      string    `json:"type"` // Currently always "task"
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"` // HTML-escaped, with matched terms wrapped in <mark></mark>
+	Completed bool      `json:"completed"`
+	Rank      float64   `json:"rank"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
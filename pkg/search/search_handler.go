@@ -0,0 +1,51 @@
+package search
+
+import (
+	"strconv"
+	"tasklybe/pkg/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Search godoc
+// @Summary      Full-text search
+// @Description  Search the logged-in user's tasks, ranked by relevance. Every word is prefix-matched.
+// @Tags         Search
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        q      query     string  true   "Search query"
+// @Param        page   query     int     false  "Page number"     default(1)
+// @Param        limit  query     int     false  "Items per page"  default(10)
+// @Success      200    {object}  dto.ResponseWrapper[dto.PaginatedResponse[ResultDTO]]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Router       /search [get]
+func (h *Handler) Search(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userId").(uint)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Cannot parse user ID", nil))
+	}
+
+	query := c.Query("q", "")
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Query parameter q is required", nil))
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	result, err := h.service.Search(userID, query, page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to search", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(result, "Search completed successfully"))
+}
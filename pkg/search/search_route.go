@@ -0,0 +1,12 @@
+package search
+
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupSearchRoutes(router fiber.Router, handler *Handler) {
+	router.Get("/search", middleware.Protected(), middleware.RequirePermission(auth.PermTasksRead), handler.Search)
+}
@@ -0,0 +1,284 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"tasklybe/pkg/dto"
+	"tasklybe/pkg/task"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// textSearchConfig is the Postgres text search configuration used for both
+// indexing and querying. "simple" avoids English-only stemming, since task
+// content is written in both Indonesian and English.
+const textSearchConfig = "simple"
+
+// Postgres marks matches in snippets with these private-use characters,
+// which are replaced with <mark></mark> once the task text around them
+// has been HTML-escaped.
+const (
+	matchStart = "\uE000"
+	matchStop  = "\uE001"
+)
+
+// headlineOptions controls the snippets produced by ts_headline.
+const headlineOptions = "StartSel=" + matchStart + ", StopSel=" + matchStop + ", MaxWords=35, MinWords=15, MaxFragments=2"
+
+// snippetRadius is the number of characters kept on each side of a match
+// when building snippets without Postgres.
+const snippetRadius = 60
+
+type Service interface {
+	Search(userID uint, query string, page, limit int) (*dto.PaginatedResponse[ResultDTO], error)
+}
+
+type service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) Service {
+	return &service{db: db}
+}
+
+// Migrate adds the full-text search column and index to the tasks table.
+// It is a no-op on databases other than Postgres.
+func Migrate(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	statements := []string{
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + textSearchConfig + `', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('` + textSearchConfig + `', coalesce(description, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchRow is the raw row scanned from the search queries.
+type searchRow struct {
+	ID          uint
+	Title       string
+	Description string
+	Completed   bool
+	UpdatedAt   time.Time
+	Rank        float64
+	Snippet     string
+}
+
+// Search finds the user's tasks matching the query, best matches first.
+func (s *service) Search(userID uint, query string, page, limit int) (*dto.PaginatedResponse[ResultDTO], error) {
+	// Default pagination
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		result := dto.NewPaginatedResponse([]ResultDTO{}, 0, page, limit)
+		return &result, nil
+	}
+
+	var rows []searchRow
+	var total int64
+	var err error
+	if s.db.Dialector.Name() == "postgres" {
+		rows, total, err = s.searchPostgres(userID, terms, offset, limit)
+	} else {
+		rows, total, err = s.searchFallback(userID, terms, offset, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ResultDTO, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, ResultDTO{
+			Type:      "task",
+			ID:        row.ID,
+			Title:     row.Title,
+			Snippet:   markMatches(row.Snippet),
+			Completed: row.Completed,
+			Rank:      row.Rank,
+			UpdatedAt: row.UpdatedAt,
+		})
+	}
+
+	result := dto.NewPaginatedResponse(results, total, page, limit)
+	return &result, nil
+}
+
+// searchPostgres queries the tsvector index, matching every term as a prefix.
+func (s *service) searchPostgres(userID uint, terms []string, offset, limit int) ([]searchRow, int64, error) {
+	prefixed := make([]string, 0, len(terms))
+	for _, term := range terms {
+		prefixed = append(prefixed, term+":*")
+	}
+	tsQuery := strings.Join(prefixed, " & ")
+
+	query := s.db.Model(&task.Task{}).
+		Where("user_id = ?", userID).
+		Where("search_vector @@ to_tsquery(?, ?)", textSearchConfig, tsQuery)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []searchRow
+	err := query.
+		Select(
+			"id, title, completed, updated_at, "+
+				"ts_rank(search_vector, to_tsquery(?, ?)) AS rank, "+
+				"ts_headline(?, coalesce(title, '') || ' ' || coalesce(description, ''), to_tsquery(?, ?), ?) AS snippet",
+			textSearchConfig, tsQuery,
+			textSearchConfig, textSearchConfig, tsQuery, headlineOptions,
+		).
+		Order("rank DESC, updated_at DESC").
+		Offset(offset).Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// searchFallback uses LIKE matching for databases without full-text search.
+// Ranking is approximated by counting matched terms, with title matches
+// weighted above description matches.
+func (s *service) searchFallback(userID uint, terms []string, offset, limit int) ([]searchRow, int64, error) {
+	query := s.db.Model(&task.Task{}).Where("user_id = ?", userID)
+	for _, term := range terms {
+		pattern := "%" + term + "%"
+		query = query.Where("(LOWER(title) LIKE ? OR LOWER(description) LIKE ?)", pattern, pattern)
+	}
+
+	var candidates []searchRow
+	if err := query.Select("id, title, description, completed, updated_at").Scan(&candidates).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for i := range candidates {
+		row := &candidates[i]
+		title := strings.ToLower(row.Title)
+		description := strings.ToLower(row.Description)
+		for _, term := range terms {
+			if strings.Contains(title, term) {
+				row.Rank += 1
+			}
+			if strings.Contains(description, term) {
+				row.Rank += 0.4
+			}
+		}
+		row.Snippet = buildSnippet(row.Title+" "+row.Description, terms)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Rank != candidates[j].Rank {
+			return candidates[i].Rank > candidates[j].Rank
+		}
+		return candidates[i].UpdatedAt.After(candidates[j].UpdatedAt)
+	})
+
+	total := int64(len(candidates))
+	if offset >= len(candidates) {
+		return []searchRow{}, total, nil
+	}
+	end := offset + limit
+	if end > len(candidates) {
+		end = len(candidates)
+	}
+	return candidates[offset:end], total, nil
+}
+
+// tokenize splits a free-text query into lowercase words, dropping any
+// characters that carry meaning in tsquery syntax.
+func tokenize(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return fields
+}
+
+// markMatches HTML-escapes a snippet, then turns the match markers into
+// <mark></mark>, so task text can never inject markup. Marker characters
+// typed into a task only ever produce <mark> tags.
+func markMatches(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, matchStart, "<mark>")
+	return strings.ReplaceAll(snippet, matchStop, "</mark>")
+}
+
+// buildSnippet returns the text around the first matched term with every
+// match between the match markers.
+func buildSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length, so indexes would not line up.
+		lower = runes
+	}
+
+	first := 0
+	for i := range lower {
+		if matchLength(lower, i, terms) > 0 {
+			first = i
+			break
+		}
+	}
+
+	start := max(first-snippetRadius, 0)
+	end := min(first+snippetRadius, len(runes))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; {
+		if n := matchLength(lower, i, terms); n > 0 {
+			b.WriteString(matchStart)
+			b.WriteString(string(runes[i : i+n]))
+			b.WriteString(matchStop)
+			i += n
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// matchLength returns the length of the longest term found at position i,
+// or 0 when no term starts there.
+func matchLength(text []rune, i int, terms []string) int {
+	longest := 0
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) <= longest || i+len(t) > len(text) {
+			continue
+		}
+		if string(text[i:i+len(t)]) == term {
+			longest = len(t)
+		}
+	}
+	return longest
+}
@@ -1,12 +1,24 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"log"
 	"os"
+	"strings"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
 	"tasklybe/pkg/db"
+	"tasklybe/pkg/filter"
+	"tasklybe/pkg/mailer"
+	"tasklybe/pkg/middleware"
+	"tasklybe/pkg/planner"
+	"tasklybe/pkg/search"
 	"tasklybe/pkg/siswa"
+	"tasklybe/pkg/storage"
 	"tasklybe/pkg/task"
 	"tasklybe/pkg/user"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -18,32 +30,91 @@ func SetupApp() *fiber.App {
 	// Load .env file (it's okay if it fails on Vercel as env vars are set in dashboard)
 	_ = godotenv.Load()
 
+	// Refuse to start without a signing key rather than sign with an empty secret
+	signingKeys, err := auth.LoadKeySetFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	auth.UseKeySet(signingKeys)
+
 	// Connect to the database
 	db.ConnectDB()
+	revocationStore := auth.NewRevocationStore(db.DB)
+	mail := mailer.NewFromEnv()
+	attemptStore := auth.AttemptStoreFromEnv(db.DB)
+	loginThrottle := auth.NewLoginThrottle(attemptStore)
+	emailThrottle := auth.NewEmailThrottle(attemptStore)
+	oidcProviders := user.OIDCProvidersFromEnv()
+	auditService := audit.NewService(db.DB)
+	files := storage.NewFromEnv()
 
 	// Auto-migrate models
-	err := db.DB.AutoMigrate(&user.User{}, &task.Task{}, &siswa.Siswa{})
+	err = db.DB.AutoMigrate(&user.User{}, &user.Role{}, &user.RefreshToken{}, &user.ActionToken{}, &user.APIToken{}, &user.RecoveryCode{}, &user.OAuthState{}, &user.ExternalIdentity{}, &user.Session{}, &user.Preferences{}, &user.Invitation{}, &auth.RevokedToken{}, &auth.UserRevocation{}, &auth.LoginAttempt{}, &audit.Entry{}, &task.Task{}, &siswa.Siswa{}, &filter.SavedFilter{}, &planner.PlanItem{})
 	if err != nil {
 		log.Println("Database migration error (continuing):", err)
 	} else {
 		log.Println("Database migration completed successfully")
 
+		if err := search.Migrate(db.DB); err != nil {
+			log.Println("Search index migration error (continuing):", err)
+		}
+
+		// Long-running servers keep purging as tokens expire and grace periods end
+		go func() {
+			for ; ; time.Sleep(time.Hour) {
+				if err := revocationStore.PurgeExpired(); err != nil {
+					log.Println("Failed to purge expired token revocations:", err)
+				}
+				if err := user.PurgeDeletedAccounts(db.DB, files); err != nil {
+					log.Println("Failed to purge deleted accounts:", err)
+				}
+			}
+		}()
+
+		if err := user.MigrateUsernames(db.DB); err != nil {
+			log.Println("Failed to generate usernames:", err)
+		}
+
+		if err := user.MigrateEmails(db.DB); err != nil {
+			log.Println("Failed to lowercase email addresses:", err)
+		}
+
+		if err := user.SeedRoles(db.DB); err != nil {
+			log.Println("Failed to seed roles:", err)
+		}
+
 		// Seed default user if not exists (helpful for first-time Vercel deploy)
 		var count int64
 		db.DB.Model(&user.User{}).Count(&count)
 		if count == 0 {
 			log.Println("No users found, seeding default user 'ikhsan'...")
-			userService := user.NewService(db.DB)
-			_, _ = userService.Register(user.RegisterRequestDTO{
-				Name:     "ikhsan",
-				Email:    "ikhsan@example.com",
-				Password: "123",
-			})
+			password := os.Getenv("SEED_USER_PASSWORD")
+			if password == "" {
+				password = generateSeedPassword()
+				log.Printf("SEED_USER_PASSWORD is not set, generated password for 'ikhsan': %s", password)
+			}
+			// The first account administers the others
+			if err := user.SeedDefaultUser(db.DB, password); err != nil {
+				log.Println("Failed to seed default user:", err)
+			}
 		}
 	}
 
 	// Initialize Fiber app
-	app := fiber.New()
+	// Behind a proxy (e.g. Vercel) set PROXY_HEADER=X-Forwarded-For so per-IP
+	// login throttling sees the client address. The header is only believed
+	// from TRUSTED_PROXIES, otherwise clients could pick their own IP.
+	proxyHeader := os.Getenv("PROXY_HEADER")
+	proxies := trustedProxies()
+	if proxyHeader != "" && len(proxies) == 0 {
+		log.Printf("PROXY_HEADER is set but TRUSTED_PROXIES is empty, so %s will be ignored", proxyHeader)
+	}
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             proxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          proxies,
+		EnableIPValidation:      true,
+	})
 	app.Use(logger.New())
 
 	allowOrigins := os.Getenv("ALLOW_ORIGINS")
@@ -52,7 +123,8 @@ func SetupApp() *fiber.App {
 	}
 
 	corsConfig := cors.Config{
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, If-Match",
+		ExposeHeaders:    "ETag",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowOrigins:     allowOrigins,
 		AllowCredentials: true,
@@ -65,21 +137,69 @@ func SetupApp() *fiber.App {
 
 	app.Use(cors.New(corsConfig))
 
+	middleware.UseRevocationStore(revocationStore)
+
 	// Initialize services
-	userService := user.NewService(db.DB)
+	userService := user.NewService(db.DB, revocationStore, mail, loginThrottle, emailThrottle, auditService, files, oidcProviders)
 	taskService := task.NewService(db.DB)
 	siswaService := siswa.NewService(db.DB)
+	searchService := search.NewService(db.DB)
+	filterService := filter.NewService(db.DB, taskService)
+	plannerService := planner.NewService(db.DB, userService)
+
+	middleware.UseAPITokenAuthenticator(userService)
 
 	// Initialize handlers
 	userHandler := user.NewHandler(userService)
+	auditHandler := audit.NewHandler(auditService)
 	taskHandler := task.NewHandler(taskService)
 	siswaHandler := siswa.NewHandler(siswaService)
+	searchHandler := search.NewHandler(searchService)
+	filterHandler := filter.NewHandler(filterService)
+	plannerHandler := planner.NewHandler(plannerService)
+
+	// Public keys for services verifying our access tokens
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(fiber.Map{"keys": signingKeys.JWKS()})
+	})
+
+	// Uploaded files such as profile pictures
+	if local, ok := files.(*storage.LocalStorage); ok {
+		app.Static(storage.LocalRoute, local.Dir, fiber.Static{MaxAge: 86400})
+	}
 
 	// Setup routing
 	api := app.Group("/api")
+	api.Use(audit.RecordImpersonation(auditService))
 	user.SetupUserRoutes(api, userHandler)
+	audit.SetupAuditRoutes(api, auditHandler)
 	task.SetupTaskRoutes(api, taskHandler)
 	siswa.SetupSiswaRoutes(api, siswaHandler)
+	search.SetupSearchRoutes(api, searchHandler)
+	filter.SetupFilterRoutes(api, filterHandler)
+	planner.SetupPlannerRoutes(api, plannerHandler)
 
 	return app
 }
+
+// trustedProxies returns the proxy addresses and CIDR ranges listed in the
+// comma-separated TRUSTED_PROXIES environment variable.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// generateSeedPassword returns a random password for the seeded user.
+func generateSeedPassword() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalf("Failed to generate seed password: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -43,6 +43,9 @@ type SiswaResponseDTO struct {
 	Email        string     `json:"email"`
 	Kelas        string     `json:"kelas"`
 	TahunMasuk   int        `json:"tahun_masuk"`
+	Version      uint       `json:"version"`
+	CreatedBy    *uint      `json:"created_by"`
+	UpdatedBy    *uint      `json:"updated_by"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 }
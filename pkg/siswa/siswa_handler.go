@@ -1,8 +1,10 @@
 package siswa
 
 import (
+	"errors"
 	"strconv"
 	"tasklybe/pkg/dto"
+	"tasklybe/pkg/etag"
 	"tasklybe/pkg/validation"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,28 +18,45 @@ func NewHandler(service Service) *Handler {
 	return &Handler{service: service}
 }
 
+func (h *Handler) getUserIDFromLocals(c *fiber.Ctx) (uint, error) {
+	id, ok := c.Locals("userId").(uint)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusUnauthorized, "Cannot parse user ID")
+	}
+	return id, nil
+}
+
 // Create godoc
 // @Summary      Create a new siswa
 // @Description  Create a new student record
 // @Tags         Siswa
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        siswa  body      CreateSiswaRequestDTO  true  "Siswa data"
 // @Success      201    {object}  dto.ResponseWrapper[SiswaResponseDTO]
 // @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Failure      403    {object}  dto.ResponseWrapper[any]
 // @Failure      500    {object}  dto.ResponseWrapper[any]
 // @Router       /siswa [post]
 func (h *Handler) Create(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
 	var req CreateSiswaRequestDTO
 	if ok, errors := validation.BindAndValidate(c, &req); !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
 	}
 
-	siswa, err := h.service.Create(req)
+	siswa, err := h.service.Create(userID, req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Gagal membuat data siswa", err.Error()))
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(siswa.Version))
 	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(siswa, "Siswa berhasil ditambahkan"))
 }
 
@@ -47,6 +66,7 @@ func (h *Handler) Create(c *fiber.Ctx) error {
 // @Tags         Siswa
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        page    query     int     false  "Page number"        default(1)
 // @Param        limit   query     int     false  "Items per page"     default(10)
 // @Param        search  query     string  false  "Search by nama or NIS"
@@ -72,6 +92,7 @@ func (h *Handler) GetAll(c *fiber.Ctx) error {
 // @Tags         Siswa
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      int  true  "Siswa ID"
 // @Success      200  {object}  dto.ResponseWrapper[SiswaResponseDTO]
 // @Failure      404  {object}  dto.ResponseWrapper[any]
@@ -87,37 +108,56 @@ func (h *Handler) GetByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Siswa tidak ditemukan", err.Error()))
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(siswa.Version))
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(siswa, "Data siswa ditemukan"))
 }
 
 // Update godoc
 // @Summary      Update siswa
-// @Description  Update a student's information
+// @Description  Update a student's information. Send the siswa's ETag in If-Match to reject stale updates.
 // @Tags         Siswa
 // @Accept       json
 // @Produce      json
-// @Param        id     path      int                    true  "Siswa ID"
-// @Param        siswa  body      UpdateSiswaRequestDTO  true  "Updated siswa data"
+// @Security     ApiKeyAuth
+// @Param        id        path      int                    true   "Siswa ID"
+// @Param        If-Match  header    string                 false  "ETag of the version being updated"
+// @Param        siswa     body      UpdateSiswaRequestDTO  true   "Updated siswa data"
 // @Success      200    {object}  dto.ResponseWrapper[SiswaResponseDTO]
 // @Failure      400    {object}  dto.ResponseWrapper[any]
 // @Failure      404    {object}  dto.ResponseWrapper[any]
+// @Failure      412    {object}  dto.ResponseWrapper[SiswaResponseDTO]
 // @Router       /siswa/{id} [put]
 func (h *Handler) Update(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("ID tidak valid", err.Error()))
 	}
 
+	expectedVersion, err := etag.ParseIfMatch(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Header If-Match tidak valid", err.Error()))
+	}
+
 	var req UpdateSiswaRequestDTO
 	if ok, errors := validation.BindAndValidate(c, &req); !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
 	}
 
-	siswa, err := h.service.Update(uint(id), req)
+	siswa, err := h.service.Update(userID, uint(id), req, expectedVersion)
 	if err != nil {
+		if errors.Is(err, ErrVersionConflict) && siswa != nil {
+			c.Set(fiber.HeaderETag, etag.Format(siswa.Version))
+			return c.Status(fiber.StatusPreconditionFailed).JSON(dto.NewErrorResponseWithData(siswa, "Gagal mengupdate siswa", err.Error()))
+		}
 		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Gagal mengupdate siswa", err.Error()))
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(siswa.Version))
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(siswa, "Siswa berhasil diupdate"))
 }
 
@@ -127,17 +167,23 @@ func (h *Handler) Update(c *fiber.Ctx) error {
 // @Tags         Siswa
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      int  true  "Siswa ID"
 // @Success      200  {object}  dto.ResponseWrapper[any]
 // @Failure      404  {object}  dto.ResponseWrapper[any]
 // @Router       /siswa/{id} [delete]
 func (h *Handler) Delete(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("ID tidak valid", err.Error()))
 	}
 
-	if err := h.service.Delete(uint(id)); err != nil {
+	if err := h.service.Delete(userID, uint(id)); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Gagal menghapus siswa", err.Error()))
 	}
 
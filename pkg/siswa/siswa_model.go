@@ -19,6 +19,9 @@ type Siswa struct {
 	Email        string         `gorm:"unique" json:"email"`
 	Kelas        string         `json:"kelas"`
 	TahunMasuk   int            `json:"tahun_masuk"`
+	Version      uint           `gorm:"not null;default:1" json:"version"` // Incremented on every update, exposed as the ETag
+	CreatedBy    *uint          `gorm:"index" json:"created_by"`           // ID of the user who created the record
+	UpdatedBy    *uint          `gorm:"index" json:"updated_by"`           // ID of the user who last changed or deleted the record
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -1,12 +1,21 @@
 package siswa
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func SetupSiswaRoutes(router fiber.Router, handler *Handler) {
-	siswaGroup := router.Group("/siswa")
-	siswaGroup.Post("/", handler.Create)
-	siswaGroup.Get("/", handler.GetAll)
-	siswaGroup.Get("/:id", handler.GetByID)
-	siswaGroup.Put("/:id", handler.Update)
-	siswaGroup.Delete("/:id", handler.Delete)
+	// Student records are only visible to, and managed by, admins and teachers
+	canRead := middleware.RequirePermission(auth.PermSiswaRead)
+	canManage := middleware.RequirePermission(auth.PermSiswaWrite)
+
+	siswaGroup := router.Group("/siswa", middleware.Protected())
+	siswaGroup.Post("/", canManage, handler.Create)
+	siswaGroup.Get("/", canRead, handler.GetAll)
+	siswaGroup.Get("/:id", canRead, handler.GetByID)
+	siswaGroup.Put("/:id", canManage, handler.Update)
+	siswaGroup.Delete("/:id", canManage, handler.Delete)
 }
@@ -8,12 +8,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict is returned when a siswa was modified since the version
+// the client based its update on. The current siswa is returned alongside it.
+var ErrVersionConflict = errors.New("data siswa telah diubah oleh pengguna lain")
+
 type Service interface {
-	Create(req CreateSiswaRequestDTO) (*SiswaResponseDTO, error)
+	Create(userID uint, req CreateSiswaRequestDTO) (*SiswaResponseDTO, error)
 	GetAll(page, limit int, search string) (*dto.PaginatedResponse[SiswaResponseDTO], error)
 	GetByID(id uint) (*SiswaResponseDTO, error)
-	Update(id uint, req UpdateSiswaRequestDTO) (*SiswaResponseDTO, error)
-	Delete(id uint) error
+	Update(userID, id uint, req UpdateSiswaRequestDTO, expectedVersion *uint) (*SiswaResponseDTO, error)
+	Delete(userID, id uint) error
 }
 
 type service struct {
@@ -24,8 +28,8 @@ func NewService(db *gorm.DB) Service {
 	return &service{db: db}
 }
 
-// Create creates a new siswa.
-func (s *service) Create(req CreateSiswaRequestDTO) (*SiswaResponseDTO, error) {
+// Create creates a new siswa on behalf of the given user.
+func (s *service) Create(userID uint, req CreateSiswaRequestDTO) (*SiswaResponseDTO, error) {
 	// Check if NIS already exists
 	var existingSiswa Siswa
 	if err := s.db.Where("nis = ?", req.NIS).First(&existingSiswa).Error; err == nil {
@@ -61,6 +65,8 @@ func (s *service) Create(req CreateSiswaRequestDTO) (*SiswaResponseDTO, error) {
 		Email:        req.Email,
 		Kelas:        req.Kelas,
 		TahunMasuk:   req.TahunMasuk,
+		CreatedBy:    &userID,
+		UpdatedBy:    &userID,
 	}
 
 	if err := s.db.Create(&newSiswa).Error; err != nil {
@@ -125,8 +131,10 @@ func (s *service) GetByID(id uint) (*SiswaResponseDTO, error) {
 	return s.toResponseDTO(&siswa), nil
 }
 
-// Update updates a siswa by ID.
-func (s *service) Update(id uint, req UpdateSiswaRequestDTO) (*SiswaResponseDTO, error) {
+// Update updates a siswa by ID on behalf of the given user. When
+// expectedVersion is set, the update only succeeds if the siswa is still
+// at that version.
+func (s *service) Update(userID, id uint, req UpdateSiswaRequestDTO, expectedVersion *uint) (*SiswaResponseDTO, error) {
 	var siswa Siswa
 	if err := s.db.First(&siswa, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -134,6 +142,9 @@ func (s *service) Update(id uint, req UpdateSiswaRequestDTO) (*SiswaResponseDTO,
 		}
 		return nil, err
 	}
+	if expectedVersion != nil && siswa.Version != *expectedVersion {
+		return s.toResponseDTO(&siswa), ErrVersionConflict
+	}
 
 	// Check if NIS is being changed and already exists
 	if req.NIS != "" && req.NIS != siswa.NIS {
@@ -183,23 +194,38 @@ func (s *service) Update(id uint, req UpdateSiswaRequestDTO) (*SiswaResponseDTO,
 		siswa.TahunMasuk = req.TahunMasuk
 	}
 
-	if err := s.db.Save(&siswa).Error; err != nil {
-		return nil, err
-	}
-
-	return s.toResponseDTO(&siswa), nil
-}
+	siswa.UpdatedBy = &userID
 
-// Delete soft deletes a siswa by ID.
-func (s *service) Delete(id uint) error {
-	result := s.db.Delete(&Siswa{}, id)
+	// Only write if nobody else saved the record since it was read
+	readVersion := siswa.Version
+	siswa.Version++
+	result := s.db.Model(&siswa).Where("version = ?", readVersion).Select("*").Updates(&siswa)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
 	if result.RowsAffected == 0 {
-		return errors.New("siswa tidak ditemukan")
+		current, err := s.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		return current, ErrVersionConflict
 	}
-	return nil
+
+	return s.toResponseDTO(&siswa), nil
+}
+
+// Delete soft deletes a siswa by ID, recording the user who deleted it.
+func (s *service) Delete(userID, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Siswa{}).Where("id = ?", id).Update("updated_by", userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("siswa tidak ditemukan")
+		}
+		return tx.Delete(&Siswa{}, id).Error
+	})
 }
 
 // toResponseDTO converts a Siswa model to SiswaResponseDTO.
@@ -216,6 +242,9 @@ func (s *service) toResponseDTO(siswa *Siswa) *SiswaResponseDTO {
 		Email:        siswa.Email,
 		Kelas:        siswa.Kelas,
 		TahunMasuk:   siswa.TahunMasuk,
+		Version:      siswa.Version,
+		CreatedBy:    siswa.CreatedBy,
+		UpdatedBy:    siswa.UpdatedBy,
 		CreatedAt:    siswa.CreatedAt,
 		UpdatedAt:    siswa.UpdatedAt,
 	}
@@ -0,0 +1,78 @@
+package siswa
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Siswa{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db).(*service)
+}
+
+func TestUpdateChecksExpectedVersion(t *testing.T) {
+	s := newTestService(t)
+	created, err := s.Create(1, CreateSiswaRequestDTO{NIS: "1001", Nama: "Budi", JenisKelamin: "L"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := created.Version
+	updated, err := s.Update(1, created.ID, UpdateSiswaRequestDTO{Kelas: "7B"}, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != version+1 {
+		t.Fatalf("got version %d, want %d", updated.Version, version+1)
+	}
+
+	current, err := s.Update(2, created.ID, UpdateSiswaRequestDTO{Kelas: "7C"}, &version)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if current == nil || current.Version != updated.Version || current.Kelas != "7B" {
+		t.Fatalf("conflict returned %+v, want the saved record", current)
+	}
+}
+
+func TestUpdateDetectsConcurrentWrite(t *testing.T) {
+	s := newTestService(t)
+	created, err := s.Create(1, CreateSiswaRequestDTO{NIS: "1001", Nama: "Budi", JenisKelamin: "L"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another request saves the record after this one has read it
+	interfered := false
+	if err := s.db.Callback().Update().Before("gorm:update").Register("test:concurrent_write", func(db *gorm.DB) {
+		if interfered {
+			return
+		}
+		interfered = true
+		db.Session(&gorm.Session{NewDB: true}).
+			Exec("UPDATE siswas SET kelas = ?, version = version + 1 WHERE id = ?", "7A", created.ID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := s.Update(2, created.ID, UpdateSiswaRequestDTO{Kelas: "7C"}, nil)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if current.Kelas != "7A" || current.Version != created.Version+1 {
+		t.Fatalf("conflict returned %+v, want the other request's save", current)
+	}
+}
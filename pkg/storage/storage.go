@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// Storage keeps uploaded files under slash-separated keys such as
+// "avatars/12/abc-256.jpg" and tells clients where to fetch them.
+type Storage interface {
+	// Put stores data under key, replacing any existing file.
+	Put(key string, data []byte, contentType string) error
+	// Delete removes the file under key. Missing files are not an error.
+	Delete(key string) error
+	// URL returns the public URL of the file under key.
+	URL(key string) string
+}
+
+// NewFromEnv creates the Storage selected by STORAGE_DRIVER:
+//   - "local" (the default) writes to STORAGE_LOCAL_DIR (default "uploads"),
+//     served at LocalRoute or under STORAGE_PUBLIC_URL when set
+func NewFromEnv() Storage {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver != "" && driver != "local" {
+		log.Printf("Warning: unknown STORAGE_DRIVER %q, using local storage", driver)
+	}
+
+	dir := os.Getenv("STORAGE_LOCAL_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	baseURL := os.Getenv("STORAGE_PUBLIC_URL")
+	if baseURL == "" {
+		baseURL = LocalRoute
+	}
+	return NewLocalStorage(dir, baseURL)
+}
+
+// validKey reports whether key is a clean relative path that cannot
+// escape the storage root.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return errors.New("invalid storage key: " + key)
+	}
+	return nil
+}
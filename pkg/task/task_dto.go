@@ -1,5 +1,7 @@
 package task
 
+import "time"
+
 // CreateTaskDTO defines the structure for creating a new task.
 type CreateTaskDTO struct {
 	Title       string `json:"title" validate:"required"`
@@ -14,3 +16,30 @@ type UpdateTaskDTO struct {
 	Description *string `json:"description"`
 	Completed   *bool   `json:"completed"`
 }
+
+// SnoozeTaskDTO defines the structure for snoozing a task.
+type SnoozeTaskDTO struct {
+	Until time.Time `json:"until" validate:"required"` // RFC 3339 timestamp
+}
+
+// DuplicateTaskDTO defines the options for duplicating a task.
+type DuplicateTaskDTO struct {
+	Title          *string `json:"title"`           // Defaults to the original title with a " (copy)" suffix
+	CopyCompletion bool    `json:"copy_completion"` // Keep the completed flag instead of resetting it
+}
+
+// Task status values accepted by TaskFilter.
+const (
+	StatusAll       = "all"
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+)
+
+// TaskFilter defines the optional criteria for listing tasks.
+// The zero value lists every task.
+type TaskFilter struct {
+	Status string `query:"status" json:"status" validate:"omitempty,oneof=all pending completed"`
+	Search string `query:"search" json:"search"` // Case-insensitive match on title or description
+	// IncludeSnoozed also lists tasks whose snooze has not expired yet.
+	IncludeSnoozed bool `query:"include_snoozed" json:"include_snoozed"`
+}
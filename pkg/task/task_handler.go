@@ -1,8 +1,10 @@
 package task
 
 import (
+	"errors"
 	"strconv"
 	"tasklybe/pkg/dto"
+	"tasklybe/pkg/etag"
 	"tasklybe/pkg/validation"
 
 	"github.com/gofiber/fiber/v2"
@@ -24,6 +26,16 @@ func (h *Handler) getUserIDFromLocals(c *fiber.Ctx) (uint, error) {
 	return id, nil
 }
 
+// updateError responds to a failed task update. Version conflicts return
+// 412 Precondition Failed with the current task and its ETag.
+func (h *Handler) updateError(c *fiber.Ctx, current *Task, err error, message string) error {
+	if errors.Is(err, ErrVersionConflict) && current != nil {
+		c.Set(fiber.HeaderETag, etag.Format(current.Version))
+		return c.Status(fiber.StatusPreconditionFailed).JSON(dto.NewErrorResponseWithData(current, message, err.Error()))
+	}
+	return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse(message, err.Error()))
+}
+
 // CreateTask godoc
 // @Summary      Create a new task
 // @Description  Add a new task for the logged-in user
@@ -52,6 +64,7 @@ func (h *Handler) CreateTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to create task", err.Error()))
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
 	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(task, "Task created successfully"))
 }
 
@@ -61,8 +74,12 @@ func (h *Handler) CreateTask(c *fiber.Ctx) error {
 // @Tags         Task
 // @Produce      json
 // @Security     ApiKeyAuth
-// @Success      200  {object}  dto.ResponseWrapper[[]Task]
-// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Param        status  query     string  false  "Filter by status"  Enums(all, pending, completed)
+// @Param        search  query     string  false  "Search by title or description"
+// @Param        include_snoozed  query  bool  false  "Include tasks that are still snoozed"
+// @Success      200     {object}  dto.ResponseWrapper[[]Task]
+// @Failure      400     {object}  dto.ResponseWrapper[any]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
 // @Router       /tasks [get]
 func (h *Handler) GetAllTasks(c *fiber.Ctx) error {
 	userID, err := h.getUserIDFromLocals(c)
@@ -70,7 +87,12 @@ func (h *Handler) GetAllTasks(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
 	}
 
-	tasks, err := h.service.GetAllTasks(userID)
+	var filter TaskFilter
+	if ok, errors := validation.BindQueryAndValidate(c, &filter); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	tasks, err := h.service.GetAllTasks(userID, filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve tasks", err.Error()))
 	}
@@ -105,22 +127,25 @@ func (h *Handler) GetTaskByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Task not found", err.Error()))
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(task, "Task retrieved successfully"))
 }
 
 // UpdateTask godoc
 // @Summary      Update a task
-// @Description  Update a task by its ID
+// @Description  Update a task by its ID. Send the task's ETag in If-Match to reject stale updates.
 // @Tags         Task
 // @Accept       json
 // @Produce      json
 // @Security     ApiKeyAuth
-// @Param        id   path      int  true  "Task ID"
-// @Param        task body      UpdateTaskDTO  true  "Task update data"
+// @Param        id        path      int            true   "Task ID"
+// @Param        If-Match  header    string         false  "ETag of the version being updated"
+// @Param        task      body      UpdateTaskDTO  true   "Task update data"
 // @Success      200  {object}  dto.ResponseWrapper[Task]
 // @Failure      400  {object}  dto.ResponseWrapper[any]
 // @Failure      401  {object}  dto.ResponseWrapper[any]
 // @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Failure      412  {object}  dto.ResponseWrapper[Task]
 // @Router       /tasks/{id} [put]
 func (h *Handler) UpdateTask(c *fiber.Ctx) error {
 	userID, err := h.getUserIDFromLocals(c)
@@ -133,16 +158,22 @@ func (h *Handler) UpdateTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid task ID", nil))
 	}
 
+	expectedVersion, err := etag.ParseIfMatch(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid If-Match header", err.Error()))
+	}
+
 	var req UpdateTaskDTO
 	if ok, errors := validation.BindAndValidate(c, &req); !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
 	}
 
-	task, err := h.service.UpdateTask(userID, uint(taskID), req)
+	task, err := h.service.UpdateTask(userID, uint(taskID), req, expectedVersion)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to update task", err.Error()))
+		return h.updateError(c, task, err, "Failed to update task")
 	}
 
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(task, "Task updated successfully"))
 }
 
@@ -175,3 +206,117 @@ func (h *Handler) DeleteTask(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Task deleted successfully"))
 }
+
+// SnoozeTask godoc
+// @Summary      Snooze a task
+// @Description  Hide a task from default listings until the given time
+// @Tags         Task
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path      int            true  "Task ID"
+// @Param        snooze  body      SnoozeTaskDTO  true  "Snooze data"
+// @Success      200     {object}  dto.ResponseWrapper[Task]
+// @Failure      400     {object}  dto.ResponseWrapper[any]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Failure      404     {object}  dto.ResponseWrapper[any]
+// @Router       /tasks/{id}/snooze [post]
+func (h *Handler) SnoozeTask(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	taskID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid task ID", nil))
+	}
+
+	var req SnoozeTaskDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	task, err := h.service.SnoozeTask(userID, uint(taskID), req.Until)
+	if err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return h.updateError(c, task, err, "Failed to snooze task")
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to snooze task", err.Error()))
+	}
+
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(task, "Task snoozed successfully"))
+}
+
+// UnsnoozeTask godoc
+// @Summary      Unsnooze a task
+// @Description  Make a snoozed task visible again
+// @Tags         Task
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Task ID"
+// @Success      200  {object}  dto.ResponseWrapper[Task]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /tasks/{id}/snooze [delete]
+func (h *Handler) UnsnoozeTask(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	taskID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid task ID", nil))
+	}
+
+	task, err := h.service.UnsnoozeTask(userID, uint(taskID))
+	if err != nil {
+		return h.updateError(c, task, err, "Failed to unsnooze task")
+	}
+
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(task, "Task unsnoozed successfully"))
+}
+
+// DuplicateTask godoc
+// @Summary      Duplicate a task
+// @Description  Create a copy of a task. The body is optional.
+// @Tags         Task
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int               true   "Task ID"
+// @Param        options  body      DuplicateTaskDTO  false  "Duplication options"
+// @Success      201      {object}  dto.ResponseWrapper[Task]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Failure      404      {object}  dto.ResponseWrapper[any]
+// @Router       /tasks/{id}/duplicate [post]
+func (h *Handler) DuplicateTask(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	taskID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid task ID", nil))
+	}
+
+	var req DuplicateTaskDTO
+	if len(c.Body()) > 0 {
+		if ok, errors := validation.BindAndValidate(c, &req); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+		}
+	}
+
+	task, err := h.service.DuplicateTask(userID, uint(taskID), req)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to duplicate task", err.Error()))
+	}
+
+	c.Set(fiber.HeaderETag, etag.Format(task.Version))
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(task, "Task duplicated successfully"))
+}
@@ -8,12 +8,14 @@ import (
 
 // Task represents the task model.
 type Task struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Title       string         `gorm:"not null" json:"title"`
-	Description string         `json:"description"`
-	Completed   bool           `gorm:"default:false" json:"completed"`
-	UserID      uint           `gorm:"not null" json:"user_id"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint           `gorm:"primarykey" json:"id"`
+	Title        string         `gorm:"not null" json:"title"`
+	Description  string         `json:"description"`
+	Completed    bool           `gorm:"default:false" json:"completed"`
+	SnoozedUntil *time.Time     `gorm:"index" json:"snoozed_until"` // Hidden from default listings until this time
+	UserID       uint           `gorm:"not null" json:"user_id"`
+	Version      uint           `gorm:"not null;default:1" json:"version"` // Incremented on every update, exposed as the ETag
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -1,17 +1,25 @@
 package task
 
 import (
+	"tasklybe/pkg/auth"
 	"tasklybe/pkg/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func SetupTaskRoutes(router fiber.Router, handler *Handler) {
-	taskGroup := router.Group("/tasks", middleware.Protected()) // Apply JWT middleware here
+	taskGroup := router.Group("/tasks",
+		middleware.Protected(), // Apply JWT middleware here
+		middleware.RequireReadWrite(auth.PermTasksRead, auth.PermTasksWrite),
+		middleware.VerifiedEmail(),
+	)
 
 	taskGroup.Post("/", handler.CreateTask)
 	taskGroup.Get("/", handler.GetAllTasks)
 	taskGroup.Get("/:id", handler.GetTaskByID)
 	taskGroup.Put("/:id", handler.UpdateTask)
 	taskGroup.Delete("/:id", handler.DeleteTask)
+	taskGroup.Post("/:id/duplicate", handler.DuplicateTask)
+	taskGroup.Post("/:id/snooze", handler.SnoozeTask)
+	taskGroup.Delete("/:id/snooze", handler.UnsnoozeTask)
 }
@@ -2,16 +2,25 @@ package task
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict is returned when a task was modified since the version
+// the client based its update on. The current task is returned alongside it.
+var ErrVersionConflict = errors.New("task has been modified by another request")
+
 type Service interface {
 	CreateTask(userID uint, req CreateTaskDTO) (*Task, error)
-	GetAllTasks(userID uint) ([]Task, error)
+	GetAllTasks(userID uint, filter TaskFilter) ([]Task, error)
 	GetTaskByID(userID, taskID uint) (*Task, error)
-	UpdateTask(userID, taskID uint, req UpdateTaskDTO) (*Task, error)
+	UpdateTask(userID, taskID uint, req UpdateTaskDTO, expectedVersion *uint) (*Task, error)
 	DeleteTask(userID, taskID uint) error
+	SnoozeTask(userID, taskID uint, until time.Time) (*Task, error)
+	UnsnoozeTask(userID, taskID uint) (*Task, error)
+	DuplicateTask(userID, taskID uint, req DuplicateTaskDTO) (*Task, error)
 }
 
 type service struct {
@@ -34,9 +43,27 @@ func (s *service) CreateTask(userID uint, req CreateTaskDTO) (*Task, error) {
 	return &task, nil
 }
 
-func (s *service) GetAllTasks(userID uint) ([]Task, error) {
+func (s *service) GetAllTasks(userID uint, filter TaskFilter) ([]Task, error) {
 	var tasks []Task
-	if err := s.db.Where("user_id = ?", userID).Find(&tasks).Error; err != nil {
+	query := s.db.Where("user_id = ?", userID)
+
+	switch filter.Status {
+	case StatusPending:
+		query = query.Where("completed = ?", false)
+	case StatusCompleted:
+		query = query.Where("completed = ?", true)
+	}
+
+	if !filter.IncludeSnoozed {
+		query = query.Where("(snoozed_until IS NULL OR snoozed_until <= ?)", time.Now())
+	}
+
+	if filter.Search != "" {
+		searchPattern := "%" + strings.ToLower(filter.Search) + "%"
+		query = query.Where("(LOWER(title) LIKE ? OR LOWER(description) LIKE ?)", searchPattern, searchPattern)
+	}
+
+	if err := query.Order("created_at DESC").Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 	return tasks, nil
@@ -53,11 +80,16 @@ func (s *service) GetTaskByID(userID, taskID uint) (*Task, error) {
 	return &task, nil
 }
 
-func (s *service) UpdateTask(userID, taskID uint, req UpdateTaskDTO) (*Task, error) {
+// UpdateTask applies the provided fields to a task. When expectedVersion is
+// set, the update only succeeds if the task is still at that version.
+func (s *service) UpdateTask(userID, taskID uint, req UpdateTaskDTO, expectedVersion *uint) (*Task, error) {
 	task, err := s.GetTaskByID(userID, taskID)
 	if err != nil {
 		return nil, err
 	}
+	if expectedVersion != nil && task.Version != *expectedVersion {
+		return task, ErrVersionConflict
+	}
 
 	if req.Title != nil {
 		task.Title = *req.Title
@@ -69,10 +101,7 @@ func (s *service) UpdateTask(userID, taskID uint, req UpdateTaskDTO) (*Task, err
 		task.Completed = *req.Completed
 	}
 
-	if err := s.db.Save(&task).Error; err != nil {
-		return nil, err
-	}
-	return task, nil
+	return s.saveVersioned(task)
 }
 
 func (s *service) DeleteTask(userID, taskID uint) error {
@@ -85,4 +114,83 @@ func (s *service) DeleteTask(userID, taskID uint) error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// SnoozeTask hides a task from default listings until the given time.
+func (s *service) SnoozeTask(userID, taskID uint, until time.Time) (*Task, error) {
+	if !until.After(time.Now()) {
+		return nil, errors.New("snooze time must be in the future")
+	}
+
+	task, err := s.GetTaskByID(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	task.SnoozedUntil = &until
+	return s.saveVersioned(task)
+}
+
+// UnsnoozeTask makes a snoozed task visible again immediately.
+func (s *service) UnsnoozeTask(userID, taskID uint) (*Task, error) {
+	task, err := s.GetTaskByID(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	task.SnoozedUntil = nil
+	return s.saveVersioned(task)
+}
+
+// DuplicateTask creates a copy of a task inside a single transaction.
+// The copy is never snoozed, and starts incomplete unless CopyCompletion is set.
+func (s *service) DuplicateTask(userID, taskID uint, req DuplicateTaskDTO) (*Task, error) {
+	var duplicate Task
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var original Task
+		if err := tx.Where("id = ? AND user_id = ?", taskID, userID).First(&original).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("task not found")
+			}
+			return err
+		}
+
+		duplicate = Task{
+			Title:       original.Title + " (copy)",
+			Description: original.Description,
+			UserID:      userID,
+		}
+		if req.Title != nil && *req.Title != "" {
+			duplicate.Title = *req.Title
+		}
+		if req.CopyCompletion {
+			duplicate.Completed = original.Completed
+		}
+
+		return tx.Create(&duplicate).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &duplicate, nil
+}
+
+// saveVersioned writes a modified task and bumps its version, failing with
+// ErrVersionConflict if another request saved the task in the meantime.
+func (s *service) saveVersioned(task *Task) (*Task, error) {
+	readVersion := task.Version
+	task.Version++
+
+	result := s.db.Model(task).Where("version = ?", readVersion).Select("*").Updates(task)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		current, err := s.GetTaskByID(task.UserID, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		return current, ErrVersionConflict
+	}
+	return task, nil
+}
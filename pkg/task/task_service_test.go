@@ -0,0 +1,94 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Task{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db).(*service)
+}
+
+func TestUpdateTaskChecksExpectedVersion(t *testing.T) {
+	s := newTestService(t)
+	created, err := s.CreateTask(1, CreateTaskDTO{Title: "Grade essays"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Version != 1 {
+		t.Fatalf("new task has version %d, want 1", created.Version)
+	}
+
+	title := "Grade essays for 7B"
+	version := uint(1)
+	updated, err := s.UpdateTask(1, created.ID, UpdateTaskDTO{Title: &title}, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || updated.Title != title {
+		t.Fatalf("got %q at version %d, want %q at version 2", updated.Title, updated.Version, title)
+	}
+
+	// The client still holds version 1
+	stale := "Grade essays for 7C"
+	current, err := s.UpdateTask(1, created.ID, UpdateTaskDTO{Title: &stale}, &version)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if current == nil || current.Version != 2 || current.Title != title {
+		t.Fatalf("conflict returned %+v, want the task at version 2", current)
+	}
+
+	// Without If-Match any version is overwritten
+	if updated, err = s.UpdateTask(1, created.ID, UpdateTaskDTO{Title: &stale}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 3 {
+		t.Fatalf("got version %d, want 3", updated.Version)
+	}
+}
+
+func TestSaveVersionedDetectsConcurrentWrite(t *testing.T) {
+	s := newTestService(t)
+	created, err := s.CreateTask(1, CreateTaskDTO{Title: "Grade essays"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two requests read version 1; the first one saves
+	first, err := s.GetTaskByID(1, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.GetTaskByID(1, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Completed = true
+	if _, err := s.saveVersioned(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second.Title = "Lost update"
+	current, err := s.saveVersioned(second)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if current.Version != 2 || !current.Completed || current.Title != "Grade essays" {
+		t.Fatalf("conflict returned %+v, want the first request's save", current)
+	}
+}
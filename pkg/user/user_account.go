@@ -0,0 +1,234 @@
+package user
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/filter"
+	"tasklybe/pkg/planner"
+	"tasklybe/pkg/siswa"
+	"tasklybe/pkg/storage"
+	"tasklybe/pkg/task"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const defaultDeletionGracePeriod = 14 * 24 * time.Hour
+
+// deletionGracePeriod returns how long a deleted account can still be
+// restored, from ACCOUNT_DELETION_GRACE.
+func deletionGracePeriod() time.Duration {
+	return durationFromEnv("ACCOUNT_DELETION_GRACE", defaultDeletionGracePeriod)
+}
+
+// ExportData collects the user's personal data.
+func (s *service) ExportData(userID uint) (*AccountExportDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := AccountExportDTO{
+		ExportedAt: time.Now(),
+		Profile:    *s.toResponseDTO(user),
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&export.Tasks).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&export.SavedFilters).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Preload("Task").Where("user_id = ?", userID).Order("day, position").Find(&export.Plan).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&export.LinkedAccounts).Error; err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = s.ListSessions(userID, ""); err != nil {
+		return nil, err
+	}
+	if export.APITokens, err = s.ListAPITokens(userID); err != nil {
+		return nil, err
+	}
+	prefs, err := loadPreferences(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	export.Preferences = *toPreferencesDTO(prefs)
+	if export.Activity, err = audit.ForUser(s.db, userID); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// WriteExportZIP writes an export as a ZIP archive with one JSON file per section.
+func WriteExportZIP(w io.Writer, export *AccountExportDTO) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"tasks.json", export.Tasks},
+		{"saved_filters.json", export.SavedFilters},
+		{"my_day.json", export.Plan},
+		{"sessions.json", export.Sessions},
+		{"api_tokens.json", export.APITokens},
+		{"linked_accounts.json", export.LinkedAccounts},
+		{"preferences.json", export.Preferences},
+		{"activity.json", export.Activity},
+	}
+	for _, file := range files {
+		fw, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ScheduleDeletion marks the account for deletion after the grace period
+// and logs it out everywhere. Logging in again and cancelling restores it.
+// The last active admin cannot delete their account.
+func (s *service) ScheduleDeletion(userID uint, req DeleteAccountRequestDTO) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, errors.New("password is incorrect")
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, errors.New("account deletion is already scheduled")
+	}
+
+	deleteAt := time.Now().Add(deletionGracePeriod())
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Someone must be left to administer the other accounts
+		if hasRole(user, auth.RoleAdmin) {
+			if err := ensureOtherAdmin(tx, user.ID); errors.Is(err, errLastAdmin) {
+				return errors.New("the last admin cannot delete their account")
+			} else if err != nil {
+				return err
+			}
+		}
+		return tx.Model(user).Update("deletion_scheduled_at", deleteAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+
+	user.DeletionScheduledAt = &deleteAt
+	return s.toResponseDTO(user), nil
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion.
+func (s *service) CancelDeletion(userID uint) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil, errors.New("account deletion is not scheduled")
+	}
+
+	if err := s.db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = nil
+	return s.toResponseDTO(user), nil
+}
+
+// PurgeDeletedAccounts permanently deletes accounts whose deletion grace
+// period has ended, together with everything they own, including their
+// pictures in files.
+func PurgeDeletedAccounts(db *gorm.DB, files storage.Storage) error {
+	var users []User
+	if err := db.Select("id", "email", "pending_email", "avatar_key").
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at < ?", time.Now()).
+		Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := purgeUser(db, user); err != nil {
+			return err
+		}
+		deleteAvatarFiles(files, user.AvatarKey)
+		log.Printf("Purged deleted account %d", user.ID)
+	}
+	return nil
+}
+
+// purgeUser hard-deletes a user and all rows owned by them in one
+// transaction, and removes their personal data from the audit log.
+func purgeUser(db *gorm.DB, user User) error {
+	userID := user.ID
+	return db.Transaction(func(tx *gorm.DB) error {
+		owned := []interface{}{
+			&planner.PlanItem{},
+			&filter.SavedFilter{},
+			&task.Task{},
+			&RefreshToken{},
+			&ActionToken{},
+			&APIToken{},
+			&RecoveryCode{},
+			&Session{},
+			&ExternalIdentity{},
+			&Preferences{},
+			&auth.RevokedToken{},
+			&auth.UserRevocation{},
+		}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Student records outlive the teacher who edited them
+		if err := tx.Model(&siswa.Siswa{}).Unscoped().Where("created_by = ?", userID).UpdateColumn("created_by", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&siswa.Siswa{}).Unscoped().Where("updated_by = ?", userID).UpdateColumn("updated_by", nil).Error; err != nil {
+			return err
+		}
+
+		// Invitations are the inviter's; accepted ones just forget who used them
+		if err := tx.Where("created_by_id = ?", userID).Delete(&Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Invitation{}).Where("used_by_id = ?", userID).UpdateColumn("used_by_id", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("key = ?", accountKeyFor(userID)).Delete(&auth.LoginAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := audit.AnonymizeUser(tx, userID, user.Email, user.PendingEmail); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&user).Error
+	})
+}
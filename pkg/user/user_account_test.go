@@ -0,0 +1,71 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"tasklybe/pkg/audit"
+	"testing"
+)
+
+func TestExportDataIncludesActivity(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	other := createTestUser(t, s, "siti@school.id", true)
+	auditLog := audit.NewService(s.db)
+
+	records := []struct {
+		actor  audit.Actor
+		action string
+		target uint
+	}{
+		{audit.Actor{UserID: other.ID}, audit.ActionUserDisable, user.ID},                           // About the user
+		{audit.Actor{UserID: user.ID, ImpersonatorID: other.ID}, audit.ActionImpersonatedChange, 0}, // Made as the user
+		{audit.Actor{UserID: user.ID}, audit.ActionInvitationCreate, 0},                             // Made by the user
+		{audit.Actor{UserID: other.ID}, audit.ActionUserUnlock, other.ID},                           // Someone else's
+	}
+	for _, record := range records {
+		targetType := ""
+		if record.target != 0 {
+			targetType = audit.TargetUser
+		}
+		if err := auditLog.Record(record.actor, record.action, targetType, record.target, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export, err := s.ExportData(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, entry := range export.Activity {
+		actions = append(actions, entry.Action)
+	}
+	want := []string{audit.ActionUserDisable, audit.ActionImpersonatedChange, audit.ActionInvitationCreate}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("exported activity %v, want %v", actions, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExportZIP(&buf, export); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := archive.Open("activity.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []audit.EntryResponseDTO
+	if err := json.NewDecoder(file).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("activity.json has %d entries, want %d", len(entries), len(want))
+	}
+}
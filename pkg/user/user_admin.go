@@ -0,0 +1,248 @@
+package user
+
+import (
+	"errors"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/dto"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultImpersonationTTL = 15 * time.Minute
+
+var errAccountDisabled = errors.New("account has been disabled")
+
+// impersonationTTL returns how long an impersonation token is valid.
+func impersonationTTL() time.Duration {
+	return durationFromEnv("IMPERSONATION_EXPIRES_IN", defaultImpersonationTTL)
+}
+
+// ListUsers returns users matching the search, newest first. The search
+// matches names, usernames and email addresses.
+func (s *service) ListUsers(page, limit int, search string) (*dto.PaginatedResponse[UserResponseDTO], error) {
+	var users []User
+	var total int64
+
+	// Default pagination
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&User{})
+	if search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR username ILIKE ? OR email ILIKE ?", searchPattern, searchPattern, searchPattern)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Preload("Roles").Offset(offset).Limit(limit).Order("created_at DESC, id DESC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	responseList := make([]UserResponseDTO, 0, len(users))
+	for _, user := range users {
+		responseList = append(responseList, *s.toResponseDTO(&user))
+	}
+
+	result := dto.NewPaginatedResponse(responseList, total, page, limit)
+	return &result, nil
+}
+
+// CreateUser creates a user on someone's behalf. Users created without a
+// password are emailed a link to choose one.
+func (s *service) CreateUser(actor audit.Actor, req CreateUserRequestDTO) (*UserResponseDTO, error) {
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = []string{auth.RoleStudent}
+	}
+
+	initialPassword := req.Password
+	if initialPassword == "" {
+		// Nobody knows this password; the user sets their own through the reset link
+		random, err := newOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		initialPassword = random
+	}
+
+	newUser := User{Name: req.Name, Username: req.Username, Email: req.Email}
+	if req.EmailVerified {
+		now := time.Now()
+		newUser.EmailVerifiedAt = &now
+	}
+	if err := createUser(s.db, &newUser, initialPassword, roles); err != nil {
+		return nil, err
+	}
+
+	if req.Password == "" {
+		if err := s.sendPasswordReset(newUser); err != nil {
+			return nil, err
+		}
+	} else if !req.EmailVerified {
+		s.sendVerificationEmail(newUser)
+	}
+
+	if err := s.audit.Record(actor, audit.ActionUserCreate, audit.TargetUser, newUser.ID, map[string]interface{}{
+		"email": newUser.Email,
+		"roles": roleNames(&newUser),
+	}); err != nil {
+		return nil, err
+	}
+	return s.toResponseDTO(&newUser), nil
+}
+
+// DisableUser stops a user from signing in and logs them out everywhere.
+// Their API tokens stop working too. Admins cannot disable themselves.
+func (s *service) DisableUser(actor audit.Actor, userID uint, req DisableUserRequestDTO) (*UserResponseDTO, error) {
+	if userID == actor.UserID {
+		return nil, errors.New("you cannot disable your own account")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, errors.New("account is already disabled")
+	}
+
+	now := time.Now()
+	if err := s.db.Model(user).Update("disabled_at", now).Error; err != nil {
+		return nil, err
+	}
+	if err := s.LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+
+	var details map[string]interface{}
+	if req.Reason != "" {
+		details = map[string]interface{}{"reason": req.Reason}
+	}
+	if err := s.audit.Record(actor, audit.ActionUserDisable, audit.TargetUser, user.ID, details); err != nil {
+		return nil, err
+	}
+
+	user.DisabledAt = &now
+	return s.toResponseDTO(user), nil
+}
+
+// EnableUser lets a disabled user sign in again.
+func (s *service) EnableUser(actor audit.Actor, userID uint) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt == nil {
+		return nil, errors.New("account is not disabled")
+	}
+
+	if err := s.db.Model(user).Update("disabled_at", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, audit.ActionUserEnable, audit.TargetUser, user.ID, nil); err != nil {
+		return nil, err
+	}
+
+	user.DisabledAt = nil
+	return s.toResponseDTO(user), nil
+}
+
+// AdminResetPassword sets a new password for a user, or emails them a reset
+// link when none is given. A new password logs the user out everywhere and
+// lifts any login lockout.
+func (s *service) AdminResetPassword(actor audit.Actor, userID uint, req AdminResetPasswordRequestDTO) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	method := "email"
+	if req.NewPassword == "" {
+		if err := s.sendPasswordReset(*user); err != nil {
+			return err
+		}
+	} else {
+		method = "set"
+		if err := checkPersonalPassword(user, req.NewPassword); err != nil {
+			return err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if err := s.db.Model(user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		if err := s.LogoutAll(user.ID); err != nil {
+			return err
+		}
+		if err := s.throttle.Reset(accountKeyFor(user.ID)); err != nil {
+			return err
+		}
+	}
+
+	return s.audit.Record(actor, audit.ActionUserPasswordReset, audit.TargetUser, user.ID, map[string]interface{}{"method": method})
+}
+
+// ForceLogout logs a user out of every session.
+func (s *service) ForceLogout(actor audit.Actor, userID uint) error {
+	if err := s.LogoutAll(userID); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, audit.ActionUserLogout, audit.TargetUser, userID, nil)
+}
+
+// Impersonate issues a short-lived access token that lets an admin see the
+// app as the user does, for support. The token names the admin in its act
+// claim, belongs to no session and cannot be refreshed. Admins and disabled
+// accounts cannot be impersonated.
+func (s *service) Impersonate(actor audit.Actor, userID uint) (*ImpersonationResponseDTO, error) {
+	if userID == actor.UserID {
+		return nil, errors.New("you cannot impersonate yourself")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if hasRole(user, auth.RoleAdmin) {
+		return nil, errors.New("admins cannot be impersonated")
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	ttl := impersonationTTL()
+	token, claims, err := auth.NewAccessToken(auth.Identity{
+		UserID:         user.ID,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerifiedAt != nil,
+		Roles:          roleNames(user),
+		ImpersonatorID: actor.UserID,
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(actor, audit.ActionUserImpersonate, audit.TargetUser, user.ID, map[string]interface{}{
+		"jti":        claims.ID,
+		"expires_at": claims.ExpiresAt.Time,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &ImpersonationResponseDTO{
+		Token:          token,
+		ExpiresIn:      int64(ttl.Seconds()),
+		ImpersonatorID: actor.UserID,
+		User:           *s.toResponseDTO(user),
+	}, nil
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"tasklybe/pkg/auth"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lastUsedInterval limits how often last-used tracking writes to the database.
+const lastUsedInterval = time.Minute
+
+// CreateAPIToken creates a personal access token. The token itself is only
+// returned here; afterwards only its prefix is shown.
+func (s *service) CreateAPIToken(userID uint, req CreateAPITokenRequestDTO) (*CreatedAPITokenResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := uniqueStrings(req.Scopes)
+	for _, scope := range scopes {
+		if !auth.HasPermission(roleNames(user), scope) {
+			return nil, errors.New("your roles do not grant the scope " + scope)
+		}
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	token := auth.APITokenPrefix + secret
+
+	record := APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    token[:len(auth.APITokenPrefix)+6],
+		TokenHash: hashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		record.ExpiresAt = &expiresAt
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPITokenResponseDTO{Token: token, Info: toAPITokenResponseDTO(&record)}, nil
+}
+
+// ListAPITokens lists the user's personal access tokens, newest first.
+func (s *service) ListAPITokens(userID uint) ([]APITokenResponseDTO, error) {
+	var tokens []APIToken
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	response := make([]APITokenResponseDTO, 0, len(tokens))
+	for i := range tokens {
+		response = append(response, toAPITokenResponseDTO(&tokens[i]))
+	}
+	return response, nil
+}
+
+// RevokeAPIToken revokes one of the user's personal access tokens.
+func (s *service) RevokeAPIToken(userID, tokenID uint) error {
+	result := s.db.Model(&APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("token not found")
+	}
+	return nil
+}
+
+// AuthenticateAPIToken resolves a personal access token to claims for
+// middleware.Protected and records when and from where it was used.
+func (s *service) AuthenticateAPIToken(token, ip string) (*auth.AccessClaims, error) {
+	var record APIToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("unknown token")
+		}
+		return nil, err
+	}
+	if record.RevokedAt != nil {
+		return nil, errors.New("token has been revoked")
+	}
+	if record.ExpiresAt != nil && time.Now().After(*record.ExpiresAt) {
+		return nil, errors.New("token has expired")
+	}
+
+	user, err := s.findUser(record.UserID)
+	if err != nil {
+		return nil, errors.New("unknown token")
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	now := time.Now()
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) > lastUsedInterval || record.LastUsedIP != ip {
+		s.db.Model(&record).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	claims := &auth.AccessClaims{
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Roles:         roleNames(user),
+		Scopes:        strings.Fields(record.Scopes),
+	}
+	claims.Subject = strconv.Itoa(int(user.ID))
+	claims.ID = "api-token-" + strconv.Itoa(int(record.ID))
+	return claims, nil
+}
+
+// toAPITokenResponseDTO converts an APIToken model to APITokenResponseDTO.
+func toAPITokenResponseDTO(token *APIToken) APITokenResponseDTO {
+	return APITokenResponseDTO{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Fields(token.Scopes),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		RevokedAt:  token.RevokedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
@@ -0,0 +1,187 @@
+package user
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register decoders for the accepted formats
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"tasklybe/pkg/storage"
+)
+
+// Limits on uploaded pictures. MaxAvatarBytes stays below Fiber's default
+// request body limit.
+const (
+	MaxAvatarBytes     = 2 << 20
+	maxAvatarDimension = 4096
+	avatarJPEGQuality  = 85
+)
+
+// avatarSizes are the square sizes in pixels every picture is resized to,
+// smallest first.
+var avatarSizes = []int{64, 128, 256}
+
+// avatarTypes are the accepted image types, detected from the file content.
+var avatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+var errUnsupportedImage = errors.New("avatar must be a JPEG, PNG or GIF image")
+
+// avatarFileKey returns the storage key of one size of a picture.
+func avatarFileKey(avatarKey string, size int) string {
+	return fmt.Sprintf("%s-%d.jpg", avatarKey, size)
+}
+
+// UploadAvatar replaces the user's picture. The image is cropped to a
+// square and stored as a JPEG in every size of avatarSizes. Each upload
+// gets new keys, so caches never serve a stale picture.
+func (s *service) UploadAvatar(userID uint, data []byte) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > MaxAvatarBytes {
+		return nil, fmt.Errorf("avatar must be at most %d MB", MaxAvatarBytes>>20)
+	}
+	// Trust the content, not the file name or the client's content type
+	if !avatarTypes[http.DetectContentType(data)] {
+		return nil, errUnsupportedImage
+	}
+	// Check the dimensions before decoding, so a small file cannot claim a huge image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+	if config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return nil, fmt.Errorf("avatar must be at most %dx%d pixels", maxAvatarDimension, maxAvatarDimension)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+
+	version, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	avatarKey := fmt.Sprintf("avatars/%d/%s", user.ID, version[:16])
+
+	// Scale down from the largest size, so the full image is only read once
+	scaled := img
+	for i := len(avatarSizes) - 1; i >= 0; i-- {
+		scaled = resizeSquare(scaled, avatarSizes[i])
+		encoded, err := encodeAvatar(scaled)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.files.Put(avatarFileKey(avatarKey, avatarSizes[i]), encoded, "image/jpeg"); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := user.AvatarKey
+	if err := s.db.Model(user).Update("avatar_key", avatarKey).Error; err != nil {
+		return nil, err
+	}
+	deleteAvatarFiles(s.files, previous)
+
+	user.AvatarKey = avatarKey
+	return s.toResponseDTO(user), nil
+}
+
+// DeleteAvatar removes the user's picture.
+func (s *service) DeleteAvatar(userID uint) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AvatarKey == "" {
+		return nil, errors.New("no avatar to delete")
+	}
+
+	previous := user.AvatarKey
+	if err := s.db.Model(user).Update("avatar_key", "").Error; err != nil {
+		return nil, err
+	}
+	deleteAvatarFiles(s.files, previous)
+
+	user.AvatarKey = ""
+	return s.toResponseDTO(user), nil
+}
+
+// deleteAvatarFiles removes every size of a picture. Failures only leave
+// unreferenced files behind, so they are logged rather than returned.
+func deleteAvatarFiles(files storage.Storage, avatarKey string) {
+	if avatarKey == "" {
+		return
+	}
+	for _, size := range avatarSizes {
+		if err := files.Delete(avatarFileKey(avatarKey, size)); err != nil {
+			log.Printf("Failed to delete avatar file %s: %v", avatarFileKey(avatarKey, size), err)
+		}
+	}
+}
+
+// resizeSquare crops the middle square of img and scales it to size×size
+// pixels, averaging the source pixels behind each output pixel.
+func resizeSquare(img image.Image, size int) *image.RGBA64 {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	left := bounds.Min.X + (bounds.Dx()-side)/2
+	top := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewRGBA64(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := sourceSpan(top, side, size, y)
+		for x := 0; x < size; x++ {
+			x0, x1 := sourceSpan(left, side, size, x)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// sourceSpan returns the source pixels [from, to) behind output pixel i
+// when scaling side source pixels starting at offset to size pixels.
+func sourceSpan(offset, side, size, i int) (int, int) {
+	from := offset + i*side/size
+	to := offset + (i+1)*side/size
+	if to <= from {
+		// Enlarging: several output pixels share one source pixel
+		to = from + 1
+	}
+	return from, to
+}
+
+// encodeAvatar encodes a picture as JPEG, on a white background since
+// JPEG has no transparency.
+func encodeAvatar(img image.Image) ([]byte, error) {
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
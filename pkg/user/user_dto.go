@@ -1,13 +1,31 @@
 package user
 
+import (
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/filter"
+	"tasklybe/pkg/planner"
+	"tasklybe/pkg/task"
+	"time"
+)
+
 // RegisterRequestDTO defines the structure for the user registration request body.
 type RegisterRequestDTO struct {
 	Name     string `json:"name" validate:"required"`
+	Username string `json:"username" validate:"omitempty,username"` // Generated from Name when omitted
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=3"`
+	Password string `json:"password" validate:"required,password"`
+	// InvitationCode is required unless registration is open or the email's
+	// domain is allowed. It also sets the new user's role.
+	InvitationCode string `json:"invitation_code"`
+}
+
+// PersonalDetails lists what the password must not contain.
+func (r RegisterRequestDTO) PersonalDetails() []string {
+	return []string{r.Name, r.Username, r.Email}
 }
 
 // LoginRequestDTO defines the structure for the user login request body.
+// Identifier is an email address if it contains "@", otherwise a username.
 type LoginRequestDTO struct {
 	Identifier string `json:"identifier"`
 	Email      string `json:"email"`
@@ -16,13 +34,272 @@ type LoginRequestDTO struct {
 
 // UserResponseDTO defines the structure for user data in responses (without password).
 type UserResponseDTO struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID                  uint       `json:"id"`
+	Name                string     `json:"name"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	PendingEmail        string     `json:"pending_email,omitempty"` // Awaiting confirmation; Email is unchanged until then
+	TwoFactorEnabled    bool       `json:"two_factor_enabled"`
+	Roles               []string   `json:"roles"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	AvatarURL           string     `json:"avatar_url,omitempty"` // The largest size; empty when the user has no picture
+	// AvatarURLs maps each standard size in pixels, e.g. "64", to its URL.
+	AvatarURLs map[string]string `json:"avatar_urls,omitempty"`
 }
 
 // LoginResponseDTO defines the structure for the login response, including the JWT.
 type LoginResponseDTO struct {
-	Token string          `json:"token"`
-	User  UserResponseDTO `json:"user"`
+	Token        string          `json:"token"`
+	ExpiresIn    int64           `json:"expires_in"` // Access token lifetime in seconds
+	RefreshToken string          `json:"refresh_token"`
+	User         UserResponseDTO `json:"user"`
+}
+
+// RefreshRequestDTO defines the structure for the token refresh request body.
+type RefreshRequestDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequestDTO defines the structure for the logout request body.
+// When RefreshToken is given, it is revoked along with the access token.
+type LogoutRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequestDTO defines the structure for requesting a password reset email.
+type ForgotPasswordRequestDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequestDTO defines the structure for resetting a password with an emailed token.
+type ResetPasswordRequestDTO struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password"`
+}
+
+// VerifyEmailRequestDTO defines the structure for confirming an email address.
+type VerifyEmailRequestDTO struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequestDTO defines the structure for requesting a new verification email.
+type ResendVerificationRequestDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// UpdateProfileRequestDTO defines the structure for updating the logged-in user's profile.
+// A new email address only replaces the current one once it is confirmed.
+type UpdateProfileRequestDTO struct {
+	Name     *string `json:"name" validate:"omitempty,min=1"`
+	Username *string `json:"username" validate:"omitempty,username"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+}
+
+// ChangePasswordRequestDTO defines the structure for changing the logged-in user's password.
+type ChangePasswordRequestDTO struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// SetRolesRequestDTO defines the structure for replacing a user's roles.
+type SetRolesRequestDTO struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,oneof=admin teacher student"`
+}
+
+// CreateUserRequestDTO defines the structure for an admin creating a user.
+// Without a Password the user is emailed a link to choose one.
+type CreateUserRequestDTO struct {
+	Name          string   `json:"name" validate:"required"`
+	Username      string   `json:"username" validate:"omitempty,username"` // Generated from Name when omitted
+	Email         string   `json:"email" validate:"required,email"`
+	Password      string   `json:"password" validate:"omitempty,password"`
+	Roles         []string `json:"roles" validate:"omitempty,dive,oneof=admin teacher student"` // Defaults to student
+	EmailVerified bool     `json:"email_verified"`                                              // Skip the verification email
+}
+
+// PersonalDetails lists what the password must not contain.
+func (r CreateUserRequestDTO) PersonalDetails() []string {
+	return []string{r.Name, r.Username, r.Email}
+}
+
+// DisableUserRequestDTO defines the structure for disabling an account.
+type DisableUserRequestDTO struct {
+	Reason string `json:"reason"` // Kept in the audit log
+}
+
+// AdminResetPasswordRequestDTO defines the structure for an admin resetting
+// a user's password. Without a NewPassword the user is emailed a reset link.
+type AdminResetPasswordRequestDTO struct {
+	NewPassword string `json:"new_password" validate:"omitempty,password"`
+}
+
+// ImpersonationResponseDTO defines the response for an impersonation token.
+// The token carries an act claim naming the admin and cannot be refreshed.
+type ImpersonationResponseDTO struct {
+	Token          string          `json:"token"`
+	ExpiresIn      int64           `json:"expires_in"` // Token lifetime in seconds
+	ImpersonatorID uint            `json:"impersonator_id"`
+	User           UserResponseDTO `json:"user"`
+}
+
+// CreateInvitationRequestDTO defines the structure for inviting someone to
+// register. Only admins can invite with a role other than student.
+type CreateInvitationRequestDTO struct {
+	Email         string `json:"email" validate:"omitempty,email"`                      // Emailed the invitation, and the only address that may use it
+	Role          string `json:"role" validate:"omitempty,oneof=admin teacher student"` // Defaults to student
+	ExpiresInDays int    `json:"expires_in_days" validate:"omitempty,min=1,max=30"`     // Defaults to 7
+}
+
+// InvitationResponseDTO defines the structure for an invitation in responses.
+type InvitationResponseDTO struct {
+	ID          uint       `json:"id"`
+	Email       string     `json:"email,omitempty"`
+	Role        string     `json:"role"`
+	CreatedByID uint       `json:"created_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	UsedByID    *uint      `json:"used_by_id"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreatedInvitationResponseDTO defines the response for a new invitation.
+// Code and Link are only ever returned here.
+type CreatedInvitationResponseDTO struct {
+	Code string                `json:"code"`
+	Link string                `json:"link"` // Registration page with the code filled in
+	Info InvitationResponseDTO `json:"info"`
+}
+
+// RoleResponseDTO defines the structure for a role and its permissions in responses.
+type RoleResponseDTO struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreateAPITokenRequestDTO defines the structure for creating a personal access token.
+type CreateAPITokenRequestDTO struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=tasks:read tasks:write siswa:read siswa:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1"` // Never expires when omitted
+}
+
+// APITokenResponseDTO defines the structure for a personal access token in responses.
+type APITokenResponseDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPITokenResponseDTO defines the response for a new personal access token.
+// Token is only ever returned here.
+type CreatedAPITokenResponseDTO struct {
+	Token string              `json:"token"`
+	Info  APITokenResponseDTO `json:"info"`
+}
+
+// TwoFactorChallengeDTO defines the login response when the password was
+// correct but a TOTP or recovery code is still required.
+type TwoFactorChallengeDTO struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int64  `json:"expires_in"` // Challenge lifetime in seconds
+}
+
+// LoginTwoFactorRequestDTO defines the structure for the second login step.
+// Code is either a TOTP code or a recovery code.
+type LoginTwoFactorRequestDTO struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// TwoFactorEnrollmentDTO defines the response when starting 2FA enrollment.
+type TwoFactorEnrollmentDTO struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // Render as a QR code for authenticator apps
+}
+
+// TwoFactorCodeRequestDTO defines the structure for requests confirmed with a 2FA code.
+type TwoFactorCodeRequestDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// DisableTwoFactorRequestDTO defines the structure for turning 2FA off.
+type DisableTwoFactorRequestDTO struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// RecoveryCodesDTO defines the response containing new recovery codes.
+// They are only ever shown once.
+type RecoveryCodesDTO struct {
+	Codes []string `json:"codes"`
+}
+
+// OIDCAuthorizeResponseDTO defines the response when starting an OIDC sign-in.
+type OIDCAuthorizeResponseDTO struct {
+	AuthorizationURL string `json:"authorization_url"` // Redirect the browser here
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequestDTO defines the structure for completing an OIDC sign-in
+// with the parameters the provider redirected back with.
+type OIDCCallbackRequestDTO struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+// SessionResponseDTO defines the structure for an active login session in responses.
+type SessionResponseDTO struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"` // The session making the request
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// DeleteAccountRequestDTO defines the structure for deleting the logged-in user's account.
+type DeleteAccountRequestDTO struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// AccountExportDTO defines the personal data export of a user.
+type AccountExportDTO struct {
+	ExportedAt     time.Time                `json:"exported_at"`
+	Profile        UserResponseDTO          `json:"profile"`
+	Tasks          []task.Task              `json:"tasks"`
+	SavedFilters   []filter.SavedFilter     `json:"saved_filters"`
+	Plan           []planner.PlanItem       `json:"plan"`
+	Sessions       []SessionResponseDTO     `json:"sessions"`
+	APITokens      []APITokenResponseDTO    `json:"api_tokens"`
+	LinkedAccounts []ExternalIdentity       `json:"linked_accounts"`
+	Preferences    PreferencesDTO           `json:"preferences"`
+	Activity       []audit.EntryResponseDTO `json:"activity"` // Audit log entries by or about the user
+}
+
+// PreferencesDTO defines the structure for a user's preferences in responses.
+type PreferencesDTO struct {
+	Timezone           string `json:"timezone"`
+	Locale             string `json:"locale"`
+	WeekStart          string `json:"week_start"`
+	EmailNotifications bool   `json:"email_notifications"`
+	InAppNotifications bool   `json:"in_app_notifications"`
+}
+
+// UpdatePreferencesRequestDTO defines the structure for updating preferences.
+// Omitted fields keep their current value.
+type UpdatePreferencesRequestDTO struct {
+	Timezone           *string `json:"timezone" validate:"omitempty,timezone"`
+	Locale             *string `json:"locale" validate:"omitempty,oneof=id en"`
+	WeekStart          *string `json:"week_start" validate:"omitempty,oneof=monday sunday"`
+	EmailNotifications *bool   `json:"email_notifications"`
+	InAppNotifications *bool   `json:"in_app_notifications"`
 }
@@ -1,6 +1,13 @@
 package user
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
 	"tasklybe/pkg/dto"
 	"tasklybe/pkg/validation"
 
@@ -15,15 +22,29 @@ func NewHandler(service Service) *Handler {
 	return &Handler{service: service}
 }
 
+func (h *Handler) getUserIDFromLocals(c *fiber.Ctx) (uint, error) {
+	id, ok := c.Locals("userId").(uint)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusUnauthorized, "Cannot parse user ID")
+	}
+	return id, nil
+}
+
+// clientInfo describes the device making the request, for session tracking.
+func clientInfo(c *fiber.Ctx) ClientInfo {
+	return ClientInfo{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+}
+
 // Register godoc
 // @Summary      Register a new user
-// @Description  Create a new user account
+// @Description  Create a new user account. Depending on the registration mode, an invitation code may be required.
 // @Tags         User
 // @Accept       json
 // @Produce      json
 // @Param        user  body      RegisterRequestDTO  true  "User registration data"
 // @Success      201  {object}  dto.ResponseWrapper[UserResponseDTO]
 // @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]  "An invitation is required"
 // @Failure      500  {object}  dto.ResponseWrapper[any]
 // @Router       /user/register [post]
 func (h *Handler) Register(c *fiber.Ctx) error {
@@ -33,6 +54,12 @@ func (h *Handler) Register(c *fiber.Ctx) error {
 	}
 
 	user, err := h.service.Register(req)
+	if errors.Is(err, errInvitationRequired) {
+		return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Failed to register user", err.Error()))
+	}
+	if errors.Is(err, errInvalidInvitation) {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to register user", err.Error()))
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to register user", err.Error()))
 	}
@@ -48,8 +75,11 @@ func (h *Handler) Register(c *fiber.Ctx) error {
 // @Produce      json
 // @Param        credentials  body      LoginRequestDTO  true  "User login credentials"
 // @Success      200          {object}  dto.ResponseWrapper[LoginResponseDTO]
+// @Success      202          {object}  dto.ResponseWrapper[TwoFactorChallengeDTO]  "2FA is enabled; continue with /user/login/2fa"
 // @Failure      400          {object}  dto.ResponseWrapper[any]
 // @Failure      401          {object}  dto.ResponseWrapper[any]
+// @Failure      403          {object}  dto.ResponseWrapper[any]  "The account has been disabled"
+// @Failure      429          {object}  dto.ResponseWrapper[any]  "Too many failed attempts; see the Retry-After header"
 // @Router       /user/login [post]
 func (h *Handler) Login(c *fiber.Ctx) error {
 	var req LoginRequestDTO
@@ -57,10 +87,1252 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
 	}
 
-	loginData, err := h.service.Login(req)
+	loginData, err := h.service.Login(req, clientInfo(c))
+	return loginResult(c, loginData, err)
+}
+
+// LoginTwoFactor godoc
+// @Summary      Complete a two-factor login
+// @Description  Exchange the challenge token from /user/login and a TOTP or recovery code for tokens
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      LoginTwoFactorRequestDTO  true  "Challenge token and code"
+// @Success      200      {object}  dto.ResponseWrapper[LoginResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Failure      429      {object}  dto.ResponseWrapper[any]  "Too many failed attempts; see the Retry-After header"
+// @Router       /user/login/2fa [post]
+func (h *Handler) LoginTwoFactor(c *fiber.Ctx) error {
+	var req LoginTwoFactorRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	loginData, err := h.service.LoginTwoFactor(req, clientInfo(c))
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Login failed", err.Error()))
+		return loginError(c, err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(loginData, "Login successful"))
 }
+
+// loginResult responds to the first step of a login, asking for a second
+// factor when the user has 2FA enabled.
+func loginResult(c *fiber.Ctx, loginData *LoginResponseDTO, err error) error {
+	var challenge *TwoFactorChallenge
+	if errors.As(err, &challenge) {
+		data := TwoFactorChallengeDTO{
+			ChallengeToken: challenge.Token,
+			ExpiresIn:      int64(challenge.ExpiresIn.Seconds()),
+		}
+		return c.Status(fiber.StatusAccepted).JSON(dto.NewSuccessResponse(&data, "Two-factor authentication required"))
+	}
+	if err != nil {
+		return loginError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(loginData, "Login successful"))
+}
+
+// loginError responds to a failed login step, with 429 and Retry-After
+// while the account or client is locked out.
+func loginError(c *fiber.Ctx, err error) error {
+	var lockout *auth.LockoutError
+	if errors.As(err, &lockout) {
+		retryAfter := int(math.Ceil(lockout.RetryAfter.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(dto.NewErrorResponse("Login failed", err.Error()))
+	}
+	if errors.Is(err, errAccountDisabled) {
+		return c.Status(fiber.StatusForbidden).JSON(dto.NewErrorResponse("Login failed", err.Error()))
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Login failed", err.Error()))
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  Exchange a refresh token for a new access token and refresh token. Each refresh token can only be used once.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        token  body      RefreshRequestDTO  true  "Refresh token"
+// @Success      200    {object}  dto.ResponseWrapper[LoginResponseDTO]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Router       /user/refresh [post]
+func (h *Handler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	loginData, err := h.service.Refresh(req, clientInfo(c))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Token refresh failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(loginData, "Token refreshed successfully"))
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Revoke the current access token and, if given, its refresh token
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        token  body      LogoutRequestDTO  false  "Refresh token to revoke"
+// @Success      200    {object}  dto.ResponseWrapper[any]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Router       /user/logout [post]
+func (h *Handler) Logout(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	claims, ok := c.Locals("claims").(*auth.AccessClaims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse("Cannot parse token claims", nil))
+	}
+
+	var req LogoutRequestDTO
+	if len(c.Body()) > 0 {
+		if ok, errors := validation.BindAndValidate(c, &req); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+		}
+	}
+
+	if err := h.service.Logout(userID, claims, req); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Logout failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Logout successful"))
+}
+
+// LogoutAll godoc
+// @Summary      Logout from all sessions
+// @Description  Revoke every access and refresh token issued to the logged-in user
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/logout-all [post]
+func (h *Handler) LogoutAll(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	if err := h.service.LogoutAll(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Logout failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Logged out from all sessions"))
+}
+
+// ForceLogout godoc
+// @Summary      Force logout a user
+// @Description  Admin only. Revoke every access and refresh token issued to a user.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/logout [post]
+func (h *Handler) ForceLogout(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	if err := h.service.ForceLogout(audit.ActorFromContext(c), uint(id)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Force logout failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "User logged out from all sessions"))
+}
+
+// UnlockUser godoc
+// @Summary      Unlock a user
+// @Description  Admin only. Clear a user's failed login attempts and lift any lockout.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/unlock [post]
+func (h *Handler) UnlockUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	if err := h.service.UnlockUser(audit.ActorFromContext(c), uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Unlock failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "User unlocked successfully"))
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Email a password reset link. The response is the same whether or not the email is registered. Requests are rate limited per address and client IP.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ForgotPasswordRequestDTO  true  "Account email"
+// @Success      200      {object}  dto.ResponseWrapper[any]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      429      {object}  dto.ResponseWrapper[any]
+// @Failure      500      {object}  dto.ResponseWrapper[any]
+// @Router       /user/forgot-password [post]
+func (h *Handler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	if err := h.service.ForgotPassword(req, clientInfo(c)); err != nil {
+		return emailRequestError(c, "Failed to send password reset email", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "If the email is registered, a password reset link has been sent"))
+}
+
+// emailRequestError responds to a failed request for an account email,
+// with 429 and Retry-After while the address or client is rate limited.
+func emailRequestError(c *fiber.Ctx, message string, err error) error {
+	var lockout *auth.LockoutError
+	if errors.As(err, &lockout) {
+		retryAfter := int(math.Ceil(lockout.RetryAfter.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(dto.NewErrorResponse(message, "too many emails requested, try again later"))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse(message, err.Error()))
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password using an emailed reset token. All sessions are logged out.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ResetPasswordRequestDTO  true  "Reset token and new password"
+// @Success      200      {object}  dto.ResponseWrapper[any]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Router       /user/reset-password [post]
+func (h *Handler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	if err := h.service.ResetPassword(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Password reset failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Password has been reset successfully"))
+}
+
+// VerifyEmail godoc
+// @Summary      Verify email address
+// @Description  Confirm the user's email address using the token from the verification email
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      VerifyEmailRequestDTO  true  "Verification token"
+// @Success      200      {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Router       /user/verify-email [post]
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.VerifyEmail(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Email verification failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Email verified successfully"))
+}
+
+// ConfirmEmailChange godoc
+// @Summary      Confirm a new email address
+// @Description  Replace the user's email address with the one requested in the profile, using the token emailed to the new address
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      VerifyEmailRequestDTO  true  "Confirmation token"
+// @Success      200      {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Router       /user/confirm-email [post]
+func (h *Handler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req VerifyEmailRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.ConfirmEmailChange(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Email change failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Email address changed successfully"))
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Send a new verification email. The response is the same whether or not the email is registered. Requests are rate limited per address and client IP.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ResendVerificationRequestDTO  true  "Account email"
+// @Success      200      {object}  dto.ResponseWrapper[any]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      429      {object}  dto.ResponseWrapper[any]
+// @Failure      500      {object}  dto.ResponseWrapper[any]
+// @Router       /user/resend-verification [post]
+func (h *Handler) ResendVerification(c *fiber.Ctx) error {
+	var req ResendVerificationRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	if err := h.service.ResendVerification(req, clientInfo(c)); err != nil {
+		return emailRequestError(c, "Failed to send verification email", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "If the email is registered and unverified, a verification link has been sent"))
+}
+
+// GetMe godoc
+// @Summary      Get my profile
+// @Description  Get the logged-in user's profile
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /user/me [get]
+func (h *Handler) GetMe(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	user, err := h.service.GetProfile(userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("User not found", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Profile retrieved successfully"))
+}
+
+// UpdateMe godoc
+// @Summary      Update my profile
+// @Description  Update the logged-in user's name, username and email. A new email only takes effect once confirmed through the link sent to it.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        profile  body      UpdateProfileRequestDTO  true  "Profile update data"
+// @Success      200      {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/me [put]
+func (h *Handler) UpdateMe(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req UpdateProfileRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.UpdateProfile(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to update profile", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Profile updated successfully"))
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Change the logged-in user's password. Other sessions are logged out and new tokens are returned.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      ChangePasswordRequestDTO  true  "Current and new password"
+// @Success      200      {object}  dto.ResponseWrapper[LoginResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/change-password [post]
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req ChangePasswordRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	loginData, err := h.service.ChangePassword(userID, req, clientInfo(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to change password", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(loginData, "Password changed successfully"))
+}
+
+// SetRoles godoc
+// @Summary      Set a user's roles
+// @Description  Admin only. Replace a user's roles. The user's access tokens are revoked so the change applies on their next refresh.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id     path      int                 true  "User ID"
+// @Param        roles  body      SetRolesRequestDTO  true  "New roles"
+// @Success      200    {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Failure      403    {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/roles [put]
+func (h *Handler) SetRoles(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	var req SetRolesRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.SetRoles(audit.ActorFromContext(c), uint(id), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to set roles", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Roles updated successfully"))
+}
+
+// ListRoles godoc
+// @Summary      List roles
+// @Description  Admin only. List every role with the permissions it grants.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[[]RoleResponseDTO]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Router       /admin/roles [get]
+func (h *Handler) ListRoles(c *fiber.Ctx) error {
+	roles := h.service.ListRoles()
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&roles, "Roles retrieved successfully"))
+}
+
+// CreateAPIToken godoc
+// @Summary      Create an API token
+// @Description  Create a personal access token for scripts and integrations. The token is only shown in this response.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        token  body      CreateAPITokenRequestDTO  true  "Token name, scopes and expiry"
+// @Success      201    {object}  dto.ResponseWrapper[CreatedAPITokenResponseDTO]
+// @Failure      400    {object}  dto.ResponseWrapper[any]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Router       /user/tokens [post]
+func (h *Handler) CreateAPIToken(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req CreateAPITokenRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	token, err := h.service.CreateAPIToken(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to create API token", err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(token, "API token created successfully, copy it now as it will not be shown again"))
+}
+
+// ListAPITokens godoc
+// @Summary      List API tokens
+// @Description  List the logged-in user's personal access tokens
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[[]APITokenResponseDTO]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/tokens [get]
+func (h *Handler) ListAPITokens(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	tokens, err := h.service.ListAPITokens(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve API tokens", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&tokens, "API tokens retrieved successfully"))
+}
+
+// RevokeAPIToken godoc
+// @Summary      Revoke an API token
+// @Description  Revoke one of the logged-in user's personal access tokens
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Token ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /user/tokens/{id} [delete]
+func (h *Handler) RevokeAPIToken(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	tokenID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid token ID", nil))
+	}
+
+	if err := h.service.RevokeAPIToken(userID, uint(tokenID)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to revoke API token", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "API token revoked successfully"))
+}
+
+// EnrollTwoFactor godoc
+// @Summary      Start 2FA enrollment
+// @Description  Generate a TOTP secret and otpauth URI for an authenticator app. 2FA is enforced once confirmed.
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[TwoFactorEnrollmentDTO]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/2fa/enroll [post]
+func (h *Handler) EnrollTwoFactor(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	enrollment, err := h.service.EnrollTwoFactor(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to start two-factor enrollment", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(enrollment, "Scan the code with your authenticator app, then confirm it"))
+}
+
+// ConfirmTwoFactor godoc
+// @Summary      Confirm 2FA enrollment
+// @Description  Enable 2FA with a code from the authenticator app. Returns recovery codes, shown only once.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      TwoFactorCodeRequestDTO  true  "TOTP code"
+// @Success      200      {object}  dto.ResponseWrapper[RecoveryCodesDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/2fa/confirm [post]
+func (h *Handler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req TwoFactorCodeRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	codes, err := h.service.ConfirmTwoFactor(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to enable two-factor authentication", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(codes, "Two-factor authentication enabled, store your recovery codes safely"))
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable 2FA
+// @Description  Turn 2FA off. Requires the password and a TOTP or recovery code.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      DisableTwoFactorRequestDTO  true  "Password and code"
+// @Success      200      {object}  dto.ResponseWrapper[any]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/2fa/disable [post]
+func (h *Handler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req DisableTwoFactorRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	if err := h.service.DisableTwoFactor(userID, req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to disable two-factor authentication", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Two-factor authentication disabled"))
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replace the 2FA recovery codes, invalidating the old ones. Requires a TOTP or recovery code.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      TwoFactorCodeRequestDTO  true  "TOTP or recovery code"
+// @Success      200      {object}  dto.ResponseWrapper[RecoveryCodesDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req TwoFactorCodeRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to regenerate recovery codes", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(codes, "Recovery codes regenerated, store them safely"))
+}
+
+// ListOIDCProviders godoc
+// @Summary      List sign-in providers
+// @Description  List the configured OpenID Connect providers, e.g. google
+// @Tags         User
+// @Produce      json
+// @Success      200  {object}  dto.ResponseWrapper[[]string]
+// @Router       /user/oauth/providers [get]
+func (h *Handler) ListOIDCProviders(c *fiber.Ctx) error {
+	providers := h.service.OIDCProviders()
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&providers, "Sign-in providers retrieved successfully"))
+}
+
+// StartOIDCLogin godoc
+// @Summary      Start an OIDC sign-in
+// @Description  Get the provider URL to redirect the browser to. The provider redirects back with code and state.
+// @Tags         User
+// @Produce      json
+// @Param        provider  path      string  true  "Provider name, e.g. google"
+// @Success      200       {object}  dto.ResponseWrapper[OIDCAuthorizeResponseDTO]
+// @Failure      400       {object}  dto.ResponseWrapper[any]
+// @Router       /user/oauth/{provider}/authorize [get]
+func (h *Handler) StartOIDCLogin(c *fiber.Ctx) error {
+	authorize, err := h.service.StartOIDCLogin(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to start sign-in", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(authorize, "Redirect to the authorization URL to sign in"))
+}
+
+// CompleteOIDCLogin godoc
+// @Summary      Complete an OIDC sign-in
+// @Description  Exchange the code and state from the provider redirect for tokens. A new identity is linked to the account with the same email, if both the provider and Taskly have verified it.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        provider  path      string                  true  "Provider name, e.g. google"
+// @Param        request   body      OIDCCallbackRequestDTO  true  "Code and state from the redirect"
+// @Success      200       {object}  dto.ResponseWrapper[LoginResponseDTO]
+// @Success      202       {object}  dto.ResponseWrapper[TwoFactorChallengeDTO]  "2FA is enabled; continue with /user/login/2fa"
+// @Failure      400       {object}  dto.ResponseWrapper[any]
+// @Failure      401       {object}  dto.ResponseWrapper[any]
+// @Router       /user/oauth/{provider}/callback [post]
+func (h *Handler) CompleteOIDCLogin(c *fiber.Ctx) error {
+	var req OIDCCallbackRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	loginData, err := h.service.CompleteOIDCLogin(c.Params("provider"), req, clientInfo(c))
+	return loginResult(c, loginData, err)
+}
+
+// ListSessions godoc
+// @Summary      List sessions
+// @Description  List the devices the logged-in user is signed in on
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[[]SessionResponseDTO]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/sessions [get]
+func (h *Handler) ListSessions(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	currentSessionID := ""
+	if claims, ok := c.Locals("claims").(*auth.AccessClaims); ok {
+		currentSessionID = claims.SessionID
+	}
+
+	sessions, err := h.service.ListSessions(userID, currentSessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve sessions", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(&sessions, "Sessions retrieved successfully"))
+}
+
+// RevokeSession godoc
+// @Summary      End a session
+// @Description  Sign out one of the logged-in user's devices. Its tokens stop working immediately.
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Session ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /user/sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid session ID", nil))
+	}
+
+	if err := h.service.RevokeSession(userID, uint(sessionID)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to end session", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Session ended successfully"))
+}
+
+// ExportData godoc
+// @Summary      Export personal data
+// @Description  Download the logged-in user's profile, tasks, saved filters, My Day plan, sessions, API tokens, linked accounts, preferences and audit log activity as a ZIP of JSON files, or as one JSON document with format=json
+// @Tags         User
+// @Produce      application/zip
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        format  query     string  false  "zip (default) or json"
+// @Success      200     {file}    file
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/export [get]
+func (h *Handler) ExportData(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	export, err := h.service.ExportData(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to export data", err.Error()))
+	}
+
+	filename := "taskly-export-" + export.ExportedAt.Format("20060102")
+	if c.Query("format") == "json" {
+		c.Attachment(filename + ".json")
+		return c.Status(fiber.StatusOK).JSON(export)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteExportZIP(&archive, export); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to export data", err.Error()))
+	}
+	c.Attachment(filename + ".zip")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	return c.Status(fiber.StatusOK).Send(archive.Bytes())
+}
+
+// DeleteMe godoc
+// @Summary      Delete account
+// @Description  Schedule the logged-in user's account for permanent deletion after a grace period, and log out everywhere. Log in again and cancel to keep the account.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      DeleteAccountRequestDTO  true  "Password confirmation"
+// @Success      200      {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Router       /user/me [delete]
+func (h *Handler) DeleteMe(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req DeleteAccountRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.ScheduleDeletion(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to delete account", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Account scheduled for deletion"))
+}
+
+// CancelDeletion godoc
+// @Summary      Cancel account deletion
+// @Description  Keep an account that was scheduled for deletion
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/cancel-deletion [post]
+func (h *Handler) CancelDeletion(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	user, err := h.service.CancelDeletion(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to cancel account deletion", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Account deletion cancelled"))
+}
+
+// GetPreferences godoc
+// @Summary      Get preferences
+// @Description  Get the logged-in user's time zone, locale, week start and notification settings
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[PreferencesDTO]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/preferences [get]
+func (h *Handler) GetPreferences(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	prefs, err := h.service.GetPreferences(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve preferences", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(prefs, "Preferences retrieved successfully"))
+}
+
+// UpdatePreferences godoc
+// @Summary      Update preferences
+// @Description  Update the logged-in user's preferences. Omitted fields keep their current value.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        preferences  body      UpdatePreferencesRequestDTO  true  "Preferences to change"
+// @Success      200          {object}  dto.ResponseWrapper[PreferencesDTO]
+// @Failure      400          {object}  dto.ResponseWrapper[any]
+// @Failure      401          {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/preferences [put]
+func (h *Handler) UpdatePreferences(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	var req UpdatePreferencesRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	prefs, err := h.service.UpdatePreferences(userID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to update preferences", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(prefs, "Preferences updated successfully"))
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  Admin only. List users with pagination, newest first, optionally searching names, usernames and emails.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page    query     int     false  "Page number"     default(1)
+// @Param        limit   query     int     false  "Items per page"  default(10)
+// @Param        search  query     string  false  "Search by name, username or email"
+// @Success      200     {object}  dto.ResponseWrapper[dto.PaginatedResponse[UserResponseDTO]]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Failure      403     {object}  dto.ResponseWrapper[any]
+// @Failure      500     {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users [get]
+func (h *Handler) ListUsers(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	search := c.Query("search", "")
+
+	result, err := h.service.ListUsers(page, limit, search)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve users", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(result, "Users retrieved successfully"))
+}
+
+// CreateUser godoc
+// @Summary      Create a user
+// @Description  Admin only. Create a user with the given roles. Without a password, the user is emailed a link to choose one.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        user  body      CreateUserRequestDTO  true  "New user"
+// @Success      201   {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400   {object}  dto.ResponseWrapper[any]
+// @Failure      401   {object}  dto.ResponseWrapper[any]
+// @Failure      403   {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users [post]
+func (h *Handler) CreateUser(c *fiber.Ctx) error {
+	var req CreateUserRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	user, err := h.service.CreateUser(audit.ActorFromContext(c), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to create user", err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(user, "User created successfully"))
+}
+
+// DisableUser godoc
+// @Summary      Disable a user
+// @Description  Admin only. Stop a user from signing in or using any token, and log them out everywhere.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                    true   "User ID"
+// @Param        request  body      DisableUserRequestDTO  false  "Reason, kept in the audit log"
+// @Success      200      {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Failure      403      {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/disable [post]
+func (h *Handler) DisableUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	var req DisableUserRequestDTO
+	if len(c.Body()) > 0 {
+		if ok, errors := validation.BindAndValidate(c, &req); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+		}
+	}
+
+	user, err := h.service.DisableUser(audit.ActorFromContext(c), uint(id), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to disable user", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "User disabled successfully"))
+}
+
+// EnableUser godoc
+// @Summary      Enable a user
+// @Description  Admin only. Let a disabled user sign in again.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/enable [post]
+func (h *Handler) EnableUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	user, err := h.service.EnableUser(audit.ActorFromContext(c), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to enable user", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "User enabled successfully"))
+}
+
+// ResetUserPassword godoc
+// @Summary      Reset a user's password
+// @Description  Admin only. Set a new password, logging the user out everywhere, or without one email the user a reset link.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                           true   "User ID"
+// @Param        request  body      AdminResetPasswordRequestDTO  false  "New password"
+// @Success      200      {object}  dto.ResponseWrapper[any]
+// @Failure      400      {object}  dto.ResponseWrapper[any]
+// @Failure      401      {object}  dto.ResponseWrapper[any]
+// @Failure      403      {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/reset-password [post]
+func (h *Handler) ResetUserPassword(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	var req AdminResetPasswordRequestDTO
+	if len(c.Body()) > 0 {
+		if ok, errors := validation.BindAndValidate(c, &req); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+		}
+	}
+
+	if err := h.service.AdminResetPassword(audit.ActorFromContext(c), uint(id), req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Password reset failed", err.Error()))
+	}
+
+	message := "Password has been reset successfully"
+	if req.NewPassword == "" {
+		message = "A password reset link has been sent"
+	}
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, message))
+}
+
+// Impersonate godoc
+// @Summary      Impersonate a user
+// @Description  Admin only. Get a short-lived access token to act as a user for support. The token carries an act claim naming the admin, cannot be refreshed or used to change credentials, and every write made with it is recorded in the audit log.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  dto.ResponseWrapper[ImpersonationResponseDTO]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Router       /admin/users/{id}/impersonate [post]
+func (h *Handler) Impersonate(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid user ID", nil))
+	}
+
+	data, err := h.service.Impersonate(audit.ActorFromContext(c), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Impersonation failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(data, "Impersonation token issued"))
+}
+
+// CreateInvitation godoc
+// @Summary      Invite someone to register
+// @Description  Admins and teachers only. Create an invitation code, emailed when an address is given. Only admins can pre-assign a role other than student. The code is only shown in this response.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        invitation  body      CreateInvitationRequestDTO  true  "Invitee email, role and expiry"
+// @Success      201         {object}  dto.ResponseWrapper[CreatedInvitationResponseDTO]
+// @Failure      400         {object}  dto.ResponseWrapper[any]
+// @Failure      401         {object}  dto.ResponseWrapper[any]
+// @Failure      403         {object}  dto.ResponseWrapper[any]
+// @Router       /user/invitations [post]
+func (h *Handler) CreateInvitation(c *fiber.Ctx) error {
+	var req CreateInvitationRequestDTO
+	if ok, errors := validation.BindAndValidate(c, &req); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", errors))
+	}
+
+	invitation, err := h.service.CreateInvitation(audit.ActorFromContext(c), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to create invitation", err.Error()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewSuccessResponse(invitation, "Invitation created successfully"))
+}
+
+// ListInvitations godoc
+// @Summary      List invitations
+// @Description  Admins and teachers only. List invitations, newest first. Admins see every invitation, teachers their own.
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page   query     int  false  "Page number"     default(1)
+// @Param        limit  query     int  false  "Items per page"  default(10)
+// @Success      200    {object}  dto.ResponseWrapper[dto.PaginatedResponse[InvitationResponseDTO]]
+// @Failure      401    {object}  dto.ResponseWrapper[any]
+// @Failure      403    {object}  dto.ResponseWrapper[any]
+// @Failure      500    {object}  dto.ResponseWrapper[any]
+// @Router       /user/invitations [get]
+func (h *Handler) ListInvitations(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	result, err := h.service.ListInvitations(userID, page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewErrorResponse("Failed to retrieve invitations", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(result, "Invitations retrieved successfully"))
+}
+
+// RevokeInvitation godoc
+// @Summary      Revoke an invitation
+// @Description  Admins and teachers only. Stop an unused invitation from being accepted. Teachers can only revoke their own.
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Invitation ID"
+// @Success      200  {object}  dto.ResponseWrapper[any]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Failure      403  {object}  dto.ResponseWrapper[any]
+// @Failure      404  {object}  dto.ResponseWrapper[any]
+// @Router       /user/invitations/{id} [delete]
+func (h *Handler) RevokeInvitation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Invalid invitation ID", nil))
+	}
+
+	if err := h.service.RevokeInvitation(audit.ActorFromContext(c), uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(dto.NewErrorResponse("Failed to revoke invitation", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse[any](nil, "Invitation revoked successfully"))
+}
+
+// UploadAvatar godoc
+// @Summary      Upload a profile picture
+// @Description  Replace the logged-in user's picture with a JPEG, PNG or GIF of at most 2 MB. It is cropped to a square and resized to 64, 128 and 256 pixels.
+// @Tags         User
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        avatar  formData  file  true  "Image file"
+// @Success      200     {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400     {object}  dto.ResponseWrapper[any]
+// @Failure      401     {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/avatar [post]
+func (h *Handler) UploadAvatar(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Validation failed", "avatar file is required"))
+	}
+	if header.Size > MaxAvatarBytes {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to upload avatar", "avatar must be at most 2 MB"))
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to upload avatar", err.Error()))
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, MaxAvatarBytes+1))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to upload avatar", err.Error()))
+	}
+
+	user, err := h.service.UploadAvatar(userID, data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to upload avatar", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Avatar updated successfully"))
+}
+
+// DeleteAvatar godoc
+// @Summary      Remove the profile picture
+// @Description  Remove the logged-in user's picture
+// @Tags         User
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.ResponseWrapper[UserResponseDTO]
+// @Failure      400  {object}  dto.ResponseWrapper[any]
+// @Failure      401  {object}  dto.ResponseWrapper[any]
+// @Router       /user/me/avatar [delete]
+func (h *Handler) DeleteAvatar(c *fiber.Ctx) error {
+	userID, err := h.getUserIDFromLocals(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(dto.NewErrorResponse(err.Error(), nil))
+	}
+
+	user, err := h.service.DeleteAvatar(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.NewErrorResponse("Failed to delete avatar", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewSuccessResponse(user, "Avatar deleted successfully"))
+}
@@ -0,0 +1,209 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/dto"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultInvitationDays = 7
+
+var (
+	errInvitationRequired = errors.New("registration requires an invitation")
+	errInvalidInvitation  = errors.New("invalid or expired invitation code")
+)
+
+// registrationAllowed reports whether the email may register without an
+// invitation under the configured registration mode.
+func registrationAllowed(email string) bool {
+	switch auth.RegistrationMode() {
+	case auth.RegistrationOpen:
+		return true
+	case auth.RegistrationDomain:
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return false
+		}
+		return containsString(auth.RegistrationDomains(), strings.ToLower(email[at+1:]))
+	default:
+		return false
+	}
+}
+
+// CreateInvitation creates an invitation code, emailing it when the
+// invitation is for a specific address. Only admins can invite with a role
+// other than student. The code is only returned here.
+func (s *service) CreateInvitation(actor audit.Actor, req CreateInvitationRequestDTO) (*CreatedInvitationResponseDTO, error) {
+	inviter, err := s.findUser(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = auth.RoleStudent
+	}
+	if role != auth.RoleStudent && !hasRole(inviter, auth.RoleAdmin) {
+		return nil, errors.New("only admins can invite with the role " + role)
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultInvitationDays
+	}
+
+	code, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := Invitation{
+		CodeHash:    hashToken(code),
+		Email:       normalizeEmail(req.Email),
+		Role:        role,
+		CreatedByID: inviter.ID,
+		ExpiresAt:   time.Now().AddDate(0, 0, days),
+	}
+	if err := s.db.Create(&invitation).Error; err != nil {
+		return nil, err
+	}
+
+	link := appURL() + "/register?invitation=" + code
+	if invitation.Email != "" {
+		// The invitee has no preferences yet, so the defaults apply
+		msg := s.composeEmail(User{Name: inviter.Name, Email: invitation.Email}, emailInvitation, link, invitation.ExpiresAt)
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send invitation %d: %v", invitation.ID, err)
+		}
+	}
+
+	if err := s.audit.Record(actor, audit.ActionInvitationCreate, audit.TargetInvitation, invitation.ID, map[string]interface{}{
+		"email": invitation.Email,
+		"role":  invitation.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &CreatedInvitationResponseDTO{Code: code, Link: link, Info: toInvitationResponseDTO(&invitation)}, nil
+}
+
+// ListInvitations lists invitations, newest first: every invitation for
+// admins, and the user's own for everyone else.
+func (s *service) ListInvitations(userID uint, page, limit int) (*dto.PaginatedResponse[InvitationResponseDTO], error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitations []Invitation
+	var total int64
+
+	// Default pagination
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&Invitation{})
+	if !hasRole(user, auth.RoleAdmin) {
+		query = query.Where("created_by_id = ?", user.ID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC, id DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	responseList := make([]InvitationResponseDTO, 0, len(invitations))
+	for _, invitation := range invitations {
+		responseList = append(responseList, toInvitationResponseDTO(&invitation))
+	}
+
+	result := dto.NewPaginatedResponse(responseList, total, page, limit)
+	return &result, nil
+}
+
+// RevokeInvitation stops an unused invitation from being accepted. Admins
+// can revoke any invitation, everyone else only their own.
+func (s *service) RevokeInvitation(actor audit.Actor, invitationID uint) error {
+	user, err := s.findUser(actor.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := s.db.Model(&Invitation{}).Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", invitationID)
+	if !hasRole(user, auth.RoleAdmin) {
+		query = query.Where("created_by_id = ?", user.ID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return s.audit.Record(actor, audit.ActionInvitationRevoke, audit.TargetInvitation, invitationID, nil)
+}
+
+// findInvitation returns the usable invitation with the given code. An
+// invitation made out to an address only works for that address.
+func (s *service) findInvitation(code, email string) (*Invitation, error) {
+	var invitation Invitation
+	if err := s.db.Where("code_hash = ?", hashToken(code)).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidInvitation
+		}
+		return nil, err
+	}
+
+	if invitation.UsedAt != nil || invitation.RevokedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, errInvalidInvitation
+	}
+	if invitation.Email != "" && invitation.Email != normalizeEmail(email) {
+		return nil, errInvalidInvitation
+	}
+	return &invitation, nil
+}
+
+// acceptInvitation marks the invitation as used by the new user, failing if
+// a concurrent registration got there first.
+func acceptInvitation(tx *gorm.DB, invitationID, userID uint) error {
+	result := tx.Model(&Invitation{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", invitationID).
+		Updates(map[string]interface{}{"used_at": time.Now(), "used_by_id": userID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidInvitation
+	}
+	return nil
+}
+
+// toInvitationResponseDTO converts an Invitation model to InvitationResponseDTO.
+func toInvitationResponseDTO(invitation *Invitation) InvitationResponseDTO {
+	return InvitationResponseDTO{
+		ID:          invitation.ID,
+		Email:       invitation.Email,
+		Role:        invitation.Role,
+		CreatedByID: invitation.CreatedByID,
+		ExpiresAt:   invitation.ExpiresAt,
+		UsedAt:      invitation.UsedAt,
+		UsedByID:    invitation.UsedByID,
+		RevokedAt:   invitation.RevokedAt,
+		CreatedAt:   invitation.CreatedAt,
+	}
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"fmt"
+	"log"
+	"tasklybe/pkg/mailer"
+	"time"
+)
+
+// Keys of localized emails.
+const (
+	emailVerification  = "email_verification"
+	emailPasswordReset = "password_reset"
+	emailChange        = "email_change"
+	emailInvitation    = "invitation"
+)
+
+// emailTemplate is a localized email. Body placeholders are the user's
+// name (%[1]s), the link (%[2]s) and when the link expires (%[3]s). The
+// invitee has no account yet, so invitations name the inviter instead.
+type emailTemplate struct {
+	subject string
+	body    string
+}
+
+var emailTemplates = map[string]map[string]emailTemplate{
+	emailVerification: {
+		LocaleEnglish: {
+			subject: "Verify your Taskly email address",
+			body: "Hi %[1]s,\n\n" +
+				"Please confirm that this is your email address by opening the link below before %[3]s:\n\n" +
+				"%[2]s\n\n" +
+				"If you did not create a Taskly account, you can ignore this email.",
+		},
+		LocaleIndonesian: {
+			subject: "Verifikasi alamat email Taskly Anda",
+			body: "Halo %[1]s,\n\n" +
+				"Silakan konfirmasi bahwa ini adalah alamat email Anda dengan membuka tautan di bawah sebelum %[3]s:\n\n" +
+				"%[2]s\n\n" +
+				"Jika Anda tidak membuat akun Taskly, abaikan email ini.",
+		},
+	},
+	emailPasswordReset: {
+		LocaleEnglish: {
+			subject: "Reset your Taskly password",
+			body: "Hi %[1]s,\n\n" +
+				"Someone asked to reset the password for your Taskly account.\n" +
+				"Use the link below before %[3]s to choose a new password:\n\n" +
+				"%[2]s\n\n" +
+				"If you did not ask for this, you can ignore this email.",
+		},
+		LocaleIndonesian: {
+			subject: "Atur ulang kata sandi Taskly Anda",
+			body: "Halo %[1]s,\n\n" +
+				"Seseorang meminta untuk mengatur ulang kata sandi akun Taskly Anda.\n" +
+				"Gunakan tautan di bawah sebelum %[3]s untuk membuat kata sandi baru:\n\n" +
+				"%[2]s\n\n" +
+				"Jika Anda tidak memintanya, abaikan email ini.",
+		},
+	},
+	emailChange: {
+		LocaleEnglish: {
+			subject: "Confirm your new Taskly email address",
+			body: "Hi %[1]s,\n\n" +
+				"You asked to use this address for your Taskly account.\n" +
+				"Open the link below before %[3]s to confirm the change:\n\n" +
+				"%[2]s\n\n" +
+				"Until then your account keeps its current address. If you did not ask for this, you can ignore this email.",
+		},
+		LocaleIndonesian: {
+			subject: "Konfirmasi alamat email Taskly baru Anda",
+			body: "Halo %[1]s,\n\n" +
+				"Anda meminta untuk menggunakan alamat ini untuk akun Taskly Anda.\n" +
+				"Buka tautan di bawah sebelum %[3]s untuk mengonfirmasi perubahan:\n\n" +
+				"%[2]s\n\n" +
+				"Sampai saat itu akun Anda tetap memakai alamat yang sekarang. Jika Anda tidak memintanya, abaikan email ini.",
+		},
+	},
+	emailInvitation: {
+		LocaleEnglish: {
+			subject: "You have been invited to Taskly",
+			body: "Hi,\n\n" +
+				"%[1]s has invited you to join Taskly.\n" +
+				"Use the link below before %[3]s to create your account:\n\n" +
+				"%[2]s\n\n" +
+				"If you were not expecting this invitation, you can ignore this email.",
+		},
+		LocaleIndonesian: {
+			subject: "Anda diundang ke Taskly",
+			body: "Halo,\n\n" +
+				"%[1]s mengundang Anda untuk bergabung dengan Taskly.\n" +
+				"Gunakan tautan di bawah sebelum %[3]s untuk membuat akun Anda:\n\n" +
+				"%[2]s\n\n" +
+				"Jika Anda tidak mengharapkan undangan ini, abaikan email ini.",
+		},
+	},
+}
+
+// composeEmail builds a localized email for the user, showing times in
+// their time zone.
+func (s *service) composeEmail(user User, key, link string, expiresAt time.Time) mailer.Message {
+	prefs, err := loadPreferences(s.db, user.ID)
+	if err != nil {
+		log.Printf("Failed to load preferences for user %d, using defaults: %v", user.ID, err)
+		prefs = defaultPreferences(user.ID)
+	}
+
+	template, ok := emailTemplates[key][prefs.Locale]
+	if !ok {
+		template = emailTemplates[key][LocaleEnglish]
+	}
+
+	expires := formatDateTime(expiresAt.In(prefs.location()), prefs.Locale)
+	return mailer.Message{
+		To:      user.Email,
+		Subject: template.subject,
+		Body:    fmt.Sprintf(template.body, user.Name, link, expires),
+	}
+}
+
+var (
+	indonesianDays   = [...]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+	indonesianMonths = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+)
+
+// formatDateTime formats a time for people in the given locale, e.g.
+// "Monday, 19 October 2026 14:00 WIB" or "Senin, 19 Oktober 2026 14.00 WIB".
+func formatDateTime(t time.Time, locale string) string {
+	if locale == LocaleIndonesian {
+		return fmt.Sprintf("%s, %d %s %d %s",
+			indonesianDays[t.Weekday()], t.Day(), indonesianMonths[t.Month()-1], t.Year(), t.Format("15.04 MST"))
+	}
+	return t.Format("Monday, 2 January 2006 15:04 MST")
+}
@@ -9,12 +9,152 @@ import (
 
 // User represents the user model.
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"` // Omit from JSON responses
-	Tasks     []task.Task    `gorm:"foreignKey:UserID" json:"tasks,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                  uint           `gorm:"primarykey" json:"id"`
+	Name                string         `gorm:"not null" json:"name"`
+	Username            string         `gorm:"size:30;uniqueIndex" json:"username"` // Stored in lowercase
+	Email               string         `gorm:"unique;not null" json:"email"`        // Stored in lowercase
+	Password            string         `gorm:"not null" json:"-"`                   // Omit from JSON responses
+	EmailVerifiedAt     *time.Time     `json:"email_verified_at"`
+	PendingEmail        string         `json:"pending_email"`               // Replaces Email once the user confirms it
+	TOTPSecret          string         `gorm:"size:64" json:"-"`            // Set on enrollment, before 2FA is confirmed
+	TOTPEnabledAt       *time.Time     `json:"totp_enabled_at"`             // 2FA is required at login when set
+	DeletionScheduledAt *time.Time     `json:"deletion_scheduled_at"`       // The account is purged after this time unless deletion is cancelled
+	DisabledAt          *time.Time     `json:"disabled_at"`                 // Set by an admin; the user cannot sign in or use any token
+	AvatarKey           string         `gorm:"size:128" json:"-"`           // Storage key prefix of the resized pictures; empty for none
+	TOTPLastStep        int64          `gorm:"not null;default:0" json:"-"` // Last accepted time step, so codes cannot be replayed
+	Roles               []Role         `gorm:"many2many:user_roles" json:"roles,omitempty"`
+	Tasks               []task.Task    `gorm:"foreignKey:UserID" json:"tasks,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Role represents a named role; the permissions each role grants are
+// defined in the auth package.
+type Role struct {
+	ID   uint   `gorm:"primarykey" json:"id"`
+	Name string `gorm:"size:32;unique;not null" json:"name"`
+}
+
+// RefreshToken represents a single-use refresh token, stored as a SHA-256 hash.
+// Every token issued from the same login shares a FamilyID, so reuse of an
+// already rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:64;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"` // Set once the token has been exchanged for a new one
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Purposes of an ActionToken.
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change" // Confirms the user's PendingEmail
+	TokenPurposeTwoFactor         = "two_factor"   // Login challenge between the password and code steps
+)
+
+// ActionToken represents a single-use, expiring token sent to a user by email
+// or handed out during login, stored as a SHA-256 hash.
+type ActionToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"size:32;not null;index" json:"purpose"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// APIToken represents a personal access token for scripts and integrations,
+// stored as a SHA-256 hash. Scopes limit it to a subset of the owner's permissions.
+type APIToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"` // First characters of the token, to help users recognise it
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"not null" json:"-"` // Space-separated permissions
+	ExpiresAt  *time.Time `json:"expires_at"`        // Never expires when nil
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RecoveryCode represents a one-time code that can stand in for a TOTP code,
+// stored as a bcrypt hash.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OAuthState holds the PKCE verifier and nonce of a sign-in started with an
+// OIDC provider until the user comes back with an authorization code.
+type OAuthState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	StateHash    string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Provider     string    `gorm:"size:32;not null" json:"provider"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ExternalIdentity links an account at an OIDC provider to a user.
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"size:32;not null;uniqueIndex:idx_external_identity" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_external_identity" json:"subject"` // The provider's stable user ID
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session represents one login on one device. It lives as long as its
+// refresh token family, and its ID travels in access tokens as the sid claim.
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UserAgent  string     `gorm:"size:255" json:"user_agent"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"` // Updated whenever the session's tokens are refreshed
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Invitation lets someone register while registration is restricted, with
+// a role chosen by the inviter. The code is stored as a SHA-256 hash.
+type Invitation struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CodeHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Email       string     `json:"email"` // Only this address may use the invitation when set
+	Role        string     `gorm:"size:32;not null" json:"role"`
+	CreatedByID uint       `gorm:"not null;index" json:"created_by_id"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	UsedByID    *uint      `json:"used_by_id"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Preferences holds a user's personal settings. Users without a row get
+// defaultPreferences.
+type Preferences struct {
+	UserID             uint      `gorm:"primarykey" json:"-"`
+	Timezone           string    `gorm:"size:64;not null" json:"timezone"`  // IANA name, e.g. Asia/Jakarta
+	Locale             string    `gorm:"size:8;not null" json:"locale"`     // id or en
+	WeekStart          string    `gorm:"size:8;not null" json:"week_start"` // monday or sunday
+	EmailNotifications bool      `gorm:"not null" json:"email_notifications"`
+	InAppNotifications bool      `gorm:"not null" json:"in_app_notifications"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
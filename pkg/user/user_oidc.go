@@ -0,0 +1,149 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// OIDCProviders lists the names of the configured OIDC providers.
+func (s *service) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin begins the authorization code flow with PKCE, remembering
+// the verifier and nonce until the user returns.
+func (s *service) StartOIDCLogin(providerName string) (*OIDCAuthorizeResponseDTO, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New("unknown sign-in provider")
+	}
+
+	state, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := provider.AuthCodeURL(state, base64.RawURLEncoding.EncodeToString(challenge[:]), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Opportunistically clear abandoned sign-ins
+	s.db.Where("expires_at < ?", time.Now()).Delete(&OAuthState{})
+
+	if err := s.db.Create(&OAuthState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorizeResponseDTO{AuthorizationURL: authURL, State: state}, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code and logs in the user the
+// external identity belongs to. An identity seen for the first time is
+// linked to the existing user with the same verified email address.
+func (s *service) CompleteOIDCLogin(providerName string, req OIDCCallbackRequestDTO, client ClientInfo) (*LoginResponseDTO, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New("unknown sign-in provider")
+	}
+
+	// Each state can only be used once
+	var state OAuthState
+	if err := s.db.Where("state_hash = ? AND provider = ?", hashToken(req.State), providerName).First(&state).Error; err != nil {
+		return nil, errors.New("invalid or expired sign-in, please try again")
+	}
+	result := s.db.Where("id = ?", state.ID).Delete(&OAuthState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(state.ExpiresAt) {
+		return nil, errors.New("invalid or expired sign-in, please try again")
+	}
+
+	claims, err := provider.Exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != state.Nonce {
+		return nil, errors.New("invalid id_token nonce")
+	}
+
+	user, err := s.findOrLinkExternalIdentity(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, s.newTwoFactorChallenge(user.ID)
+	}
+	return s.startSession(*user, client)
+}
+
+// findOrLinkExternalIdentity returns the user linked to the identity,
+// linking it by email address if it is new. Both the provider and this app
+// must have verified the address; otherwise whoever registered it first,
+// without proving they own it, would receive the identity.
+func (s *service) findOrLinkExternalIdentity(providerName string, claims *OIDCClaims) (*User, error) {
+	var identity ExternalIdentity
+	err := s.db.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	if err == nil {
+		return s.findUser(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New("the provider did not return a verified email address")
+	}
+
+	var user User
+	if err := s.db.Where("email = ?", normalizeEmail(claims.Email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("no account is registered with this email address")
+		}
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return nil, errors.New("the account with this email address is not verified; verify it before signing in with this provider")
+	}
+
+	if err := s.db.Create(&ExternalIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return s.findUser(user.ID)
+}
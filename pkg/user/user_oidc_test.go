@@ -0,0 +1,347 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"tasklybe/pkg/totp"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID     = "taskly-test"
+	mockClientSecret = "test-secret"
+	mockRedirectURL  = "http://localhost:3000/oauth/mock/callback"
+)
+
+// mockGrant is an authorization code issued by mockIssuer.
+type mockGrant struct {
+	challenge string
+	nonce     string
+	redirect  string
+}
+
+// mockIssuer is a local OpenID Connect provider serving discovery, a JWKS
+// and a token endpoint that enforces PKCE.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+
+	// Claims of the next ID token
+	subject       string
+	email         string
+	emailVerified bool
+	nonce         string // Overrides the nonce from the authorization request when set
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &mockIssuer{key: key, grants: make(map[string]mockGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// authorize plays the user approving the sign-in at the authorization URL
+// and returns the code the provider would redirect back with.
+func (m *mockIssuer) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL has no S256 code challenge: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("authorization URL has no nonce or state: %s", authURL)
+	}
+
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.grants[code] = mockGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		redirect:  query.Get("redirect_uri"),
+	}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("client_id") != mockClientID || r.PostForm.Get("client_secret") != mockClientSecret {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || grant.redirect != r.PostForm.Get("redirect_uri") {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		http.Error(w, "invalid_grant: PKCE verification failed", http.StatusBadRequest)
+		return
+	}
+
+	nonce := grant.nonce
+	if m.nonce != "" {
+		nonce = m.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            mockClientID,
+		"sub":            m.subject,
+		"email":          m.email,
+		"email_verified": m.emailVerified,
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = "mock"
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": signed})
+}
+
+func (m *mockIssuer) provider() OIDCProvider {
+	return NewOIDCProvider(OIDCConfig{
+		Name:         "mock",
+		Issuer:       m.server.URL,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		RedirectURL:  mockRedirectURL,
+	})
+}
+
+// newOIDCTestService returns a test service with the mock issuer
+// configured as the "mock" provider.
+func newOIDCTestService(t *testing.T, issuer *mockIssuer) *service {
+	t.Helper()
+	return newTestService(t, map[string]OIDCProvider{"mock": issuer.provider()})
+}
+
+// signIn runs the whole authorization code flow for the provider's current
+// claims and returns the result of the callback.
+func signIn(t *testing.T, s *service, issuer *mockIssuer) (*LoginResponseDTO, error) {
+	t.Helper()
+	start, err := s.StartOIDCLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := issuer.authorize(t, start.AuthorizationURL)
+	return s.CompleteOIDCLogin("mock", OIDCCallbackRequestDTO{Code: code, State: start.State}, ClientInfo{IP: "127.0.0.1"})
+}
+
+func TestOIDCProviderExchangeRequiresPKCEVerifier(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.subject, issuer.email, issuer.emailVerified = "sub-1", "budi@school.id", true
+	provider := issuer.provider()
+
+	verifier := "a-code-verifier-that-is-long-enough-for-pkce"
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := provider.AuthCodeURL("state", base64.RawURLEncoding.EncodeToString(challenge[:]), "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.Exchange(issuer.authorize(t, authURL), "some-other-verifier"); err == nil {
+		t.Fatal("exchange with the wrong code verifier succeeded")
+	}
+
+	claims, err := provider.Exchange(issuer.authorize(t, authURL), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "sub-1" || claims.Email != "budi@school.id" || !claims.EmailVerified || claims.Nonce != "nonce-1" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestOIDCProviderRejectsForeignSignature(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.subject = "sub-1"
+	provider := issuer.provider()
+
+	// Sign with a key the JWKS does not publish
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.key = other
+
+	verifier := "a-code-verifier-that-is-long-enough-for-pkce"
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := provider.AuthCodeURL("state", base64.RawURLEncoding.EncodeToString(challenge[:]), "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(issuer.authorize(t, authURL), verifier); err == nil {
+		t.Fatal("exchange accepted an id_token with a foreign signature")
+	}
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name          string
+		accountEmail  string // Empty for no account
+		accountVerify bool
+		claimEmail    string
+		claimVerified bool
+		nonce         string
+		wantErr       bool
+	}{
+		{name: "links verified account", accountEmail: "budi@school.id", accountVerify: true, claimEmail: "Budi@School.id", claimVerified: true},
+		{name: "refuses unverified account", accountEmail: "budi@school.id", claimEmail: "budi@school.id", claimVerified: true, wantErr: true},
+		{name: "refuses unverified provider email", accountEmail: "budi@school.id", accountVerify: true, claimEmail: "budi@school.id", wantErr: true},
+		{name: "refuses unknown email", claimEmail: "budi@school.id", claimVerified: true, wantErr: true},
+		{name: "refuses wrong nonce", accountEmail: "budi@school.id", accountVerify: true, claimEmail: "budi@school.id", claimVerified: true, nonce: "replayed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			issuer.subject, issuer.email, issuer.emailVerified, issuer.nonce = "sub-1", tt.claimEmail, tt.claimVerified, tt.nonce
+			s := newOIDCTestService(t, issuer)
+			var account *User
+			if tt.accountEmail != "" {
+				account = createTestUser(t, s, tt.accountEmail, tt.accountVerify)
+			}
+
+			login, err := signIn(t, s, issuer)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("sign-in succeeded")
+				}
+				var identities int64
+				s.db.Model(&ExternalIdentity{}).Count(&identities)
+				if identities != 0 {
+					t.Fatal("identity was linked despite the failed sign-in")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if login.User.ID != account.ID || login.Token == "" || login.RefreshToken == "" {
+				t.Fatalf("unexpected login: %+v", login)
+			}
+
+			// Later sign-ins find the user through the linked identity
+			issuer.email = "changed@elsewhere.id"
+			again, err := signIn(t, s, issuer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.User.ID != account.ID {
+				t.Fatalf("second sign-in returned user %d, want %d", again.User.ID, account.ID)
+			}
+		})
+	}
+}
+
+func TestCompleteOIDCLoginRejectsReusedState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.subject, issuer.email, issuer.emailVerified = "sub-1", "budi@school.id", true
+	s := newOIDCTestService(t, issuer)
+	createTestUser(t, s, "budi@school.id", true)
+
+	start, err := s.StartOIDCLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := OIDCCallbackRequestDTO{Code: issuer.authorize(t, start.AuthorizationURL), State: start.State}
+	if _, err := s.CompleteOIDCLogin("mock", req, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Code = issuer.authorize(t, start.AuthorizationURL)
+	if _, err := s.CompleteOIDCLogin("mock", req, ClientInfo{}); err == nil {
+		t.Fatal("a used state was accepted again")
+	}
+}
+
+func TestCompleteOIDCLoginHandsOffToTwoFactor(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.subject, issuer.email, issuer.emailVerified = "sub-1", "budi@school.id", true
+	s := newOIDCTestService(t, issuer)
+	user := createTestUser(t, s, "budi@school.id", true)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = signIn(t, s, issuer)
+	var challenge *TwoFactorChallenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("got %v, want a two-factor challenge", err)
+	}
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := s.LoginTwoFactor(LoginTwoFactorRequestDTO{ChallengeToken: challenge.Token, Code: code}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.User.ID != user.ID || !strings.HasPrefix(login.Token, "ey") {
+		t.Fatalf("unexpected login: %+v", login)
+	}
+}
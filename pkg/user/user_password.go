@@ -0,0 +1,168 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/password"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPasswordResetTTL     = time.Hour
+	defaultEmailRequestDuration = 2 * time.Second
+)
+
+// appURL returns the frontend base URL used in emailed links.
+func appURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		url = "http://localhost:3000"
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// ForgotPassword emails a password reset link if the address belongs to a
+// user. Unknown addresses are not an error, and every request takes at least
+// EMAIL_REQUEST_MIN_DURATION, so neither the response nor its timing tells
+// callers which accounts exist.
+func (s *service) ForgotPassword(req ForgotPasswordRequestDTO, client ClientInfo) error {
+	defer padEmailRequest(time.Now())
+
+	email := normalizeEmail(req.Email)
+	if err := s.limitEmailRequest(email, client.IP); err != nil {
+		return err
+	}
+
+	var user User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.sendPasswordReset(user)
+}
+
+// limitEmailRequest counts a request to email an address, whether or not it
+// is registered, and returns a *auth.LockoutError while the address or the
+// client IP has asked too often.
+func (s *service) limitEmailRequest(email, ip string) error {
+	emailKey := auth.EmailKey(email)
+	ipKey := ""
+	if ip != "" {
+		ipKey = auth.EmailIPKey(ip)
+	}
+	if err := s.emails.Check(emailKey, ipKey); err != nil {
+		return err
+	}
+
+	// The request that reaches the limit is still served
+	var lockout *auth.LockoutError
+	if err := s.emails.Failure(emailKey, ipKey); err != nil && !errors.As(err, &lockout) {
+		return err
+	}
+	return nil
+}
+
+// padEmailRequest sleeps until EMAIL_REQUEST_MIN_DURATION (default 2s) has
+// passed since start, so requests for unknown addresses take as long as
+// ones that send an email.
+func padEmailRequest(start time.Time) {
+	time.Sleep(time.Until(start.Add(durationFromEnv("EMAIL_REQUEST_MIN_DURATION", defaultEmailRequestDuration))))
+}
+
+// sendPasswordReset emails the user a link to choose a new password.
+// Delivery failures are logged rather than returned.
+func (s *service) sendPasswordReset(user User) error {
+	ttl := durationFromEnv("PASSWORD_RESET_EXPIRES_IN", defaultPasswordResetTTL)
+	token, err := s.createActionToken(user.ID, TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := appURL() + "/reset-password?token=" + token
+	msg := s.composeEmail(user, emailPasswordReset, link, time.Now().Add(ttl))
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using an emailed reset token, then
+// revokes all of the user's sessions.
+func (s *service) ResetPassword(req ResetPasswordRequestDTO) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	var userID uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := s.consumeActionToken(tx, req.Token, TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		var user User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if err := checkPersonalPassword(&user, req.NewPassword); err != nil {
+			return err
+		}
+
+		return tx.Model(&User{}).Where("id = ?", userID).Update("password", string(hashedPassword)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.LogoutAll(userID)
+}
+
+// ChangePassword sets a new password after checking the current one. All
+// other sessions are logged out, and a fresh token pair is returned for the
+// caller in a new session.
+func (s *service) ChangePassword(userID uint, req ChangePasswordRequestDTO, client ClientInfo) (*LoginResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return nil, errors.New("current password is incorrect")
+	}
+	if err := checkPersonalPassword(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(user).Update("password", string(hashedPassword)).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+	return s.startSession(*user, client)
+}
+
+// checkPersonalPassword rejects a new password containing the user's own
+// details. Requests that carry those details are checked during validation.
+func checkPersonalPassword(user *User, newPassword string) error {
+	if password.ContainsPersonal(newPassword, user.Name, user.Username, user.Email) {
+		return password.ErrPersonal
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/mailer"
+	"testing"
+	"time"
+)
+
+// recordingMailer keeps sent messages instead of delivering them.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+func TestForgotPasswordSendsBeforeReturning(t *testing.T) {
+	t.Setenv("EMAIL_REQUEST_MIN_DURATION", "50ms")
+	s := newTestService(t, nil)
+	mail := &recordingMailer{}
+	s.mailer = mail
+	createTestUser(t, s, "budi@school.id", true)
+
+	for _, email := range []string{"nobody@school.id", "Budi@School.id"} {
+		start := time.Now()
+		if err := s.ForgotPassword(ForgotPasswordRequestDTO{Email: email}, ClientInfo{IP: "203.0.113.7"}); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("request for %s returned after %s, want at least 50ms", email, elapsed)
+		}
+	}
+	if mail.count() != 1 || mail.sent[0].To != "budi@school.id" {
+		t.Fatalf("sent %+v, want one email to budi@school.id", mail.sent)
+	}
+}
+
+func TestEmailRequestsAreRateLimited(t *testing.T) {
+	t.Setenv("EMAIL_REQUEST_MIN_DURATION", "1ms")
+	s := newTestService(t, nil)
+	mail := &recordingMailer{}
+	s.mailer = mail
+	createTestUser(t, s, "budi@school.id", false)
+
+	client := ClientInfo{IP: "203.0.113.7"}
+	for i := 0; i < s.emails.AccountThreshold; i++ {
+		if err := s.ResendVerification(ResendVerificationRequestDTO{Email: "budi@school.id"}, client); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	var lockout *auth.LockoutError
+	if err := s.ForgotPassword(ForgotPasswordRequestDTO{Email: "budi@school.id"}, client); !errors.As(err, &lockout) {
+		t.Fatalf("got %v, want a lockout", err)
+	}
+	if mail.count() != s.emails.AccountThreshold {
+		t.Fatalf("sent %d emails, want %d", mail.count(), s.emails.AccountThreshold)
+	}
+
+	// Other addresses can still be requested from the same IP, up to its own limit
+	for i := s.emails.AccountThreshold; i < s.emails.IPThreshold; i++ {
+		if err := s.ForgotPassword(ForgotPasswordRequestDTO{Email: fmt.Sprintf("user%d@school.id", i)}, client); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	if err := s.ForgotPassword(ForgotPasswordRequestDTO{Email: "siti@school.id"}, client); !errors.As(err, &lockout) {
+		t.Fatalf("got %v, want a lockout of the IP", err)
+	}
+	if err := s.ForgotPassword(ForgotPasswordRequestDTO{Email: "siti@school.id"}, ClientInfo{IP: "198.51.100.1"}); err != nil {
+		t.Fatalf("another client was refused: %v", err)
+	}
+}
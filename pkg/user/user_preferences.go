@@ -0,0 +1,138 @@
+package user
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Supported locales.
+const (
+	LocaleEnglish    = "en"
+	LocaleIndonesian = "id"
+)
+
+// defaultPreferences returns the preferences of a user who never changed
+// them. DEFAULT_TIMEZONE and DEFAULT_LOCALE override the built-in defaults.
+func defaultPreferences(userID uint) Preferences {
+	prefs := Preferences{
+		UserID:             userID,
+		Timezone:           "UTC",
+		Locale:             LocaleEnglish,
+		WeekStart:          "monday",
+		EmailNotifications: true,
+		InAppNotifications: true,
+	}
+	if tz := os.Getenv("DEFAULT_TIMEZONE"); tz != "" {
+		if _, err := time.LoadLocation(tz); err == nil {
+			prefs.Timezone = tz
+		}
+	}
+	if locale := os.Getenv("DEFAULT_LOCALE"); locale == LocaleEnglish || locale == LocaleIndonesian {
+		prefs.Locale = locale
+	}
+	return prefs
+}
+
+// loadPreferences returns the user's preferences, or the defaults.
+func loadPreferences(db *gorm.DB, userID uint) (Preferences, error) {
+	var prefs Preferences
+	err := db.Where("user_id = ?", userID).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultPreferences(userID), nil
+	}
+	return prefs, err
+}
+
+// GetPreferences returns the user's preferences.
+func (s *service) GetPreferences(userID uint) (*PreferencesDTO, error) {
+	if _, err := s.findUser(userID); err != nil {
+		return nil, err
+	}
+	prefs, err := loadPreferences(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toPreferencesDTO(prefs), nil
+}
+
+// UpdatePreferences changes the given preferences and keeps the rest.
+func (s *service) UpdatePreferences(userID uint, req UpdatePreferencesRequestDTO) (*PreferencesDTO, error) {
+	if _, err := s.findUser(userID); err != nil {
+		return nil, err
+	}
+	prefs, err := loadPreferences(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Timezone != nil {
+		prefs.Timezone = *req.Timezone
+	}
+	if req.Locale != nil {
+		prefs.Locale = *req.Locale
+	}
+	if req.WeekStart != nil {
+		prefs.WeekStart = *req.WeekStart
+	}
+	if req.EmailNotifications != nil {
+		prefs.EmailNotifications = *req.EmailNotifications
+	}
+	if req.InAppNotifications != nil {
+		prefs.InAppNotifications = *req.InAppNotifications
+	}
+
+	// Save writes every column, so false toggles are stored as given
+	if err := s.db.Save(&prefs).Error; err != nil {
+		return nil, err
+	}
+	return toPreferencesDTO(prefs), nil
+}
+
+// Location returns the user's time zone, falling back to UTC. It lets other
+// packages work in the user's local time without depending on this one.
+func (s *service) Location(userID uint) *time.Location {
+	prefs, err := loadPreferences(s.db, userID)
+	if err != nil {
+		return time.UTC
+	}
+	return prefs.location()
+}
+
+// WeekStart returns the day the user's weeks start on, falling back to
+// Monday.
+func (s *service) WeekStart(userID uint) time.Weekday {
+	prefs, err := loadPreferences(s.db, userID)
+	if err != nil {
+		return time.Monday
+	}
+	return prefs.weekStart()
+}
+
+func (p Preferences) weekStart() time.Weekday {
+	if p.WeekStart == "sunday" {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+func (p Preferences) location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// toPreferencesDTO converts a Preferences model to PreferencesDTO.
+func toPreferencesDTO(prefs Preferences) *PreferencesDTO {
+	return &PreferencesDTO{
+		Timezone:           prefs.Timezone,
+		Locale:             prefs.Locale,
+		WeekStart:          prefs.WeekStart,
+		EmailNotifications: prefs.EmailNotifications,
+		InAppNotifications: prefs.InAppNotifications,
+	}
+}
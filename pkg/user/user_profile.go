@@ -0,0 +1,78 @@
+package user
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// findUser retrieves a user by ID.
+func (s *service) findUser(userID uint) (*User, error) {
+	var user User
+	if err := s.db.Preload("Roles").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetProfile returns the user's own profile.
+func (s *service) GetProfile(userID uint) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.toResponseDTO(user), nil
+}
+
+// UpdateProfile changes the user's name and username. A new email address is
+// kept as pending and sent a confirmation link; it only replaces the current
+// address in ConfirmEmailChange. Asking for the current address again
+// cancels a pending change.
+func (s *service) UpdateProfile(userID uint, req UpdateProfileRequestDTO) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		if email == user.Email {
+			user.PendingEmail = ""
+		} else if email != user.PendingEmail {
+			var existingUser User
+			if err := s.db.Where("email = ? AND id != ?", email, user.ID).First(&existingUser).Error; err == nil {
+				return nil, errors.New("email already exists")
+			}
+			user.PendingEmail = email
+			emailChanged = true
+		}
+	}
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Username != nil {
+		username := normalizeUsername(*req.Username)
+		taken, err := usernameTaken(s.db, username, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, errUsernameTaken
+		}
+		user.Username = username
+	}
+
+	if err := s.db.Omit("Roles").Save(user).Error; err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		s.sendEmailChangeConfirmation(*user)
+	}
+
+	return s.toResponseDTO(user), nil
+}
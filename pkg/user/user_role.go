@@ -0,0 +1,187 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedRoles creates the known roles, gives the student role to users that
+// have no role yet, and grants the admin role to every user listed in the
+// comma-separated ADMIN_EMAILS environment variable. Only verified addresses
+// are trusted, since anyone can register an unverified one.
+func SeedRoles(db *gorm.DB) error {
+	for _, name := range auth.Roles() {
+		if err := db.Where(Role{Name: name}).FirstOrCreate(&Role{}).Error; err != nil {
+			return err
+		}
+	}
+
+	var student Role
+	if err := db.Where("name = ?", auth.RoleStudent).First(&student).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(
+		"INSERT INTO user_roles (user_id, role_id) SELECT id, ? FROM users WHERE id NOT IN (SELECT user_id FROM user_roles)",
+		student.ID,
+	).Error; err != nil {
+		return err
+	}
+
+	var admin Role
+	if err := db.Where("name = ?", auth.RoleAdmin).First(&admin).Error; err != nil {
+		return err
+	}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = normalizeEmail(email)
+		if email == "" {
+			continue
+		}
+		var user User
+		if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+			continue
+		}
+		if user.EmailVerifiedAt == nil {
+			log.Printf("Not granting admin role to %s until the address is verified", email)
+			continue
+		}
+		if err := db.Model(&user).Association("Roles").Append(&admin); err != nil {
+			log.Printf("Failed to grant admin role to %s: %v", email, err)
+		}
+	}
+	return nil
+}
+
+// roleNames returns the names of the user's roles, which must be preloaded.
+func roleNames(user *User) []string {
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// findRoles loads the roles with the given names, failing on unknown names.
+func findRoles(db *gorm.DB, names []string) ([]Role, error) {
+	for _, name := range names {
+		if !auth.IsValidRole(name) {
+			return nil, errors.New("unknown role: " + name)
+		}
+	}
+
+	var roles []Role
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) != len(names) {
+		return nil, errors.New("roles have not been seeded")
+	}
+	return roles, nil
+}
+
+// SetRoles replaces the user's roles. The last admin cannot lose the admin
+// role. The user's access tokens are revoked so the new roles take effect
+// on their next refresh.
+func (s *service) SetRoles(actor audit.Actor, userID uint, req SetRolesRequestDTO) (*UserResponseDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := findRoles(s.db, uniqueStrings(req.Roles))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if hasRole(user, auth.RoleAdmin) && !containsString(req.Roles, auth.RoleAdmin) {
+			if err := ensureOtherAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(user).Association("Roles").Replace(roles)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeAllForUser(user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, audit.ActionUserRolesSet, audit.TargetUser, user.ID, map[string]interface{}{
+		"from": roleNames(user),
+		"to":   uniqueStrings(req.Roles),
+	}); err != nil {
+		return nil, err
+	}
+
+	user.Roles = roles
+	return s.toResponseDTO(user), nil
+}
+
+// errLastAdmin is returned when a change would leave no admin.
+var errLastAdmin = errors.New("cannot remove the admin role from the last admin")
+
+// ensureOtherAdmin returns errLastAdmin unless a user other than userID is
+// an admin whose account is neither disabled nor scheduled for deletion. It
+// locks the admin role assignments until tx ends, so two concurrent changes
+// cannot each count the other user as the remaining admin.
+func ensureOtherAdmin(tx *gorm.DB, userID uint) error {
+	var adminIDs []uint
+	if err := tx.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Where("roles.name = ?", auth.RoleAdmin).
+		Where("users.deleted_at IS NULL AND users.disabled_at IS NULL AND users.deletion_scheduled_at IS NULL").
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "user_roles"}}).
+		Pluck("user_roles.user_id", &adminIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range adminIDs {
+		if id != userID {
+			return nil
+		}
+	}
+	return errLastAdmin
+}
+
+// ListRoles returns every role with the permissions it grants.
+func (s *service) ListRoles() []RoleResponseDTO {
+	roles := make([]RoleResponseDTO, 0, len(auth.Roles()))
+	for _, name := range auth.Roles() {
+		roles = append(roles, RoleResponseDTO{Name: name, Permissions: auth.RolePermissions(name)})
+	}
+	return roles
+}
+
+// hasRole reports whether the user, with roles preloaded, holds the role.
+func hasRole(user *User, role string) bool {
+	return containsString(roleNames(user), role)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	unique := make([]string, 0, len(list))
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
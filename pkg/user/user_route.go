@@ -1,9 +1,68 @@
 package user
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func SetupUserRoutes(router fiber.Router, handler *Handler) {
+	// Account routes accept login sessions only, never API tokens
+	protected := middleware.Protected()
+	sessionOnly := middleware.SessionOnly()
+	// Impersonating admins can use the app as the user, but not change credentials
+	notImpersonating := middleware.NotImpersonating()
+
 	userGroup := router.Group("/user")
 	userGroup.Post("/register", handler.Register)
 	userGroup.Post("/login", handler.Login)
+	userGroup.Post("/login/2fa", handler.LoginTwoFactor)
+	userGroup.Get("/oauth/providers", handler.ListOIDCProviders)
+	userGroup.Get("/oauth/:provider/authorize", handler.StartOIDCLogin)
+	userGroup.Post("/oauth/:provider/callback", handler.CompleteOIDCLogin)
+	userGroup.Post("/refresh", handler.Refresh)
+	userGroup.Post("/forgot-password", handler.ForgotPassword)
+	userGroup.Post("/reset-password", handler.ResetPassword)
+	userGroup.Post("/verify-email", handler.VerifyEmail)
+	userGroup.Post("/confirm-email", handler.ConfirmEmailChange)
+	userGroup.Post("/resend-verification", handler.ResendVerification)
+	userGroup.Post("/logout", protected, sessionOnly, handler.Logout)
+	userGroup.Post("/logout-all", protected, sessionOnly, notImpersonating, handler.LogoutAll)
+	userGroup.Get("/me", protected, sessionOnly, handler.GetMe)
+	userGroup.Put("/me", protected, sessionOnly, notImpersonating, handler.UpdateMe)
+	userGroup.Delete("/me", protected, sessionOnly, notImpersonating, handler.DeleteMe)
+	userGroup.Post("/me/avatar", protected, sessionOnly, handler.UploadAvatar)
+	userGroup.Delete("/me/avatar", protected, sessionOnly, handler.DeleteAvatar)
+	userGroup.Get("/me/preferences", protected, sessionOnly, handler.GetPreferences)
+	userGroup.Put("/me/preferences", protected, sessionOnly, handler.UpdatePreferences)
+	userGroup.Get("/me/export", protected, sessionOnly, notImpersonating, handler.ExportData)
+	userGroup.Post("/me/cancel-deletion", protected, sessionOnly, notImpersonating, handler.CancelDeletion)
+	userGroup.Post("/change-password", protected, sessionOnly, notImpersonating, handler.ChangePassword)
+	userGroup.Get("/sessions", protected, sessionOnly, notImpersonating, handler.ListSessions)
+	userGroup.Delete("/sessions/:id", protected, sessionOnly, notImpersonating, handler.RevokeSession)
+	userGroup.Post("/2fa/enroll", protected, sessionOnly, notImpersonating, handler.EnrollTwoFactor)
+	userGroup.Post("/2fa/confirm", protected, sessionOnly, notImpersonating, handler.ConfirmTwoFactor)
+	userGroup.Post("/2fa/disable", protected, sessionOnly, notImpersonating, handler.DisableTwoFactor)
+	userGroup.Post("/2fa/recovery-codes", protected, sessionOnly, notImpersonating, handler.RegenerateRecoveryCodes)
+	userGroup.Post("/tokens", protected, sessionOnly, notImpersonating, handler.CreateAPIToken)
+	userGroup.Get("/tokens", protected, sessionOnly, notImpersonating, handler.ListAPITokens)
+	userGroup.Delete("/tokens/:id", protected, sessionOnly, notImpersonating, handler.RevokeAPIToken)
+
+	canInvite := middleware.RequirePermission(auth.PermUsersInvite)
+	userGroup.Post("/invitations", protected, sessionOnly, notImpersonating, canInvite, handler.CreateInvitation)
+	userGroup.Get("/invitations", protected, sessionOnly, notImpersonating, canInvite, handler.ListInvitations)
+	userGroup.Delete("/invitations/:id", protected, sessionOnly, notImpersonating, canInvite, handler.RevokeInvitation)
+
+	adminGroup := router.Group("/admin", protected, sessionOnly, notImpersonating, middleware.RequireRole(auth.RoleAdmin))
+	adminGroup.Get("/roles", handler.ListRoles)
+	adminGroup.Get("/users", handler.ListUsers)
+	adminGroup.Post("/users", handler.CreateUser)
+	adminGroup.Put("/users/:id/roles", handler.SetRoles)
+	adminGroup.Post("/users/:id/logout", handler.ForceLogout)
+	adminGroup.Post("/users/:id/unlock", handler.UnlockUser)
+	adminGroup.Post("/users/:id/disable", handler.DisableUser)
+	adminGroup.Post("/users/:id/enable", handler.EnableUser)
+	adminGroup.Post("/users/:id/reset-password", handler.ResetUserPassword)
+	adminGroup.Post("/users/:id/impersonate", handler.Impersonate)
 }
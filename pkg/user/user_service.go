@@ -2,64 +2,235 @@ package user
 
 import (
 	"errors"
-	"os"
+	"log"
 	"strconv"
+	"strings"
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/dto"
+	"tasklybe/pkg/mailer"
+	"tasklybe/pkg/storage"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type Service interface {
 	Register(req RegisterRequestDTO) (*UserResponseDTO, error)
-	Login(req LoginRequestDTO) (*LoginResponseDTO, error)
+	Login(req LoginRequestDTO, client ClientInfo) (*LoginResponseDTO, error)
+	Refresh(req RefreshRequestDTO, client ClientInfo) (*LoginResponseDTO, error)
+	Logout(userID uint, claims *auth.AccessClaims, req LogoutRequestDTO) error
+	LogoutAll(userID uint) error
+	ForgotPassword(req ForgotPasswordRequestDTO, client ClientInfo) error
+	ResetPassword(req ResetPasswordRequestDTO) error
+	VerifyEmail(req VerifyEmailRequestDTO) (*UserResponseDTO, error)
+	ConfirmEmailChange(req VerifyEmailRequestDTO) (*UserResponseDTO, error)
+	ResendVerification(req ResendVerificationRequestDTO, client ClientInfo) error
+	GetProfile(userID uint) (*UserResponseDTO, error)
+	UpdateProfile(userID uint, req UpdateProfileRequestDTO) (*UserResponseDTO, error)
+	ChangePassword(userID uint, req ChangePasswordRequestDTO, client ClientInfo) (*LoginResponseDTO, error)
+	SetRoles(actor audit.Actor, userID uint, req SetRolesRequestDTO) (*UserResponseDTO, error)
+	ListRoles() []RoleResponseDTO
+	UnlockUser(actor audit.Actor, userID uint) error
+	ForceLogout(actor audit.Actor, userID uint) error
+	ListUsers(page, limit int, search string) (*dto.PaginatedResponse[UserResponseDTO], error)
+	CreateUser(actor audit.Actor, req CreateUserRequestDTO) (*UserResponseDTO, error)
+	DisableUser(actor audit.Actor, userID uint, req DisableUserRequestDTO) (*UserResponseDTO, error)
+	EnableUser(actor audit.Actor, userID uint) (*UserResponseDTO, error)
+	AdminResetPassword(actor audit.Actor, userID uint, req AdminResetPasswordRequestDTO) error
+	Impersonate(actor audit.Actor, userID uint) (*ImpersonationResponseDTO, error)
+	LoginTwoFactor(req LoginTwoFactorRequestDTO, client ClientInfo) (*LoginResponseDTO, error)
+	EnrollTwoFactor(userID uint) (*TwoFactorEnrollmentDTO, error)
+	ConfirmTwoFactor(userID uint, req TwoFactorCodeRequestDTO) (*RecoveryCodesDTO, error)
+	DisableTwoFactor(userID uint, req DisableTwoFactorRequestDTO) error
+	RegenerateRecoveryCodes(userID uint, req TwoFactorCodeRequestDTO) (*RecoveryCodesDTO, error)
+	OIDCProviders() []string
+	StartOIDCLogin(provider string) (*OIDCAuthorizeResponseDTO, error)
+	CompleteOIDCLogin(provider string, req OIDCCallbackRequestDTO, client ClientInfo) (*LoginResponseDTO, error)
+	ListSessions(userID uint, currentSessionID string) ([]SessionResponseDTO, error)
+	RevokeSession(userID, sessionID uint) error
+	UploadAvatar(userID uint, data []byte) (*UserResponseDTO, error)
+	DeleteAvatar(userID uint) (*UserResponseDTO, error)
+	ExportData(userID uint) (*AccountExportDTO, error)
+	ScheduleDeletion(userID uint, req DeleteAccountRequestDTO) (*UserResponseDTO, error)
+	CancelDeletion(userID uint) (*UserResponseDTO, error)
+	GetPreferences(userID uint) (*PreferencesDTO, error)
+	UpdatePreferences(userID uint, req UpdatePreferencesRequestDTO) (*PreferencesDTO, error)
+	Location(userID uint) *time.Location
+	WeekStart(userID uint) time.Weekday
+	CreateInvitation(actor audit.Actor, req CreateInvitationRequestDTO) (*CreatedInvitationResponseDTO, error)
+	ListInvitations(userID uint, page, limit int) (*dto.PaginatedResponse[InvitationResponseDTO], error)
+	RevokeInvitation(actor audit.Actor, invitationID uint) error
+	CreateAPIToken(userID uint, req CreateAPITokenRequestDTO) (*CreatedAPITokenResponseDTO, error)
+	ListAPITokens(userID uint) ([]APITokenResponseDTO, error)
+	RevokeAPIToken(userID, tokenID uint) error
+	AuthenticateAPIToken(token, ip string) (*auth.AccessClaims, error)
 }
 
 type service struct {
-	db *gorm.DB
+	db          *gorm.DB
+	revocations auth.RevocationStore
+	mailer      mailer.Mailer
+	throttle    *auth.LoginThrottle
+	emails      *auth.LoginThrottle
+	audit       audit.Service
+	files       storage.Storage
+
+	oidcProviders map[string]OIDCProvider
 }
 
-func NewService(db *gorm.DB) Service {
-	return &service{db: db}
+func NewService(db *gorm.DB, revocations auth.RevocationStore, mailer mailer.Mailer, throttle, emails *auth.LoginThrottle, auditLog audit.Service, files storage.Storage, oidcProviders map[string]OIDCProvider) Service {
+	return &service{
+		db:            db,
+		revocations:   revocations,
+		mailer:        mailer,
+		throttle:      throttle,
+		emails:        emails,
+		audit:         auditLog,
+		files:         files,
+		oidcProviders: oidcProviders,
+	}
 }
 
-// Register creates a new user.
+// Register creates a new user. Depending on REGISTRATION_MODE, an
+// invitation code may be required; it decides the user's role, and
+// invitations sent to the address also verify it.
 func (s *service) Register(req RegisterRequestDTO) (*UserResponseDTO, error) {
+	// New users start out as students
+	role := auth.RoleStudent
+	email := normalizeEmail(req.Email)
+	var invitation *Invitation
+	if req.InvitationCode != "" {
+		found, err := s.findInvitation(req.InvitationCode, email)
+		if err != nil {
+			return nil, err
+		}
+		invitation = found
+		role = invitation.Role
+	} else if !registrationAllowed(email) {
+		return nil, errInvitationRequired
+	}
+
+	newUser := User{Name: req.Name, Username: req.Username, Email: email}
+	if invitation != nil && invitation.Email != "" {
+		// The code was emailed to this address
+		now := time.Now()
+		newUser.EmailVerifiedAt = &now
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := createUser(tx, &newUser, req.Password, []string{role}); err != nil {
+			return err
+		}
+		if invitation != nil {
+			return acceptInvitation(tx, invitation.ID, newUser.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask the user to confirm their address
+	if newUser.EmailVerifiedAt == nil {
+		s.sendVerificationEmail(newUser)
+	}
+
+	return s.toResponseDTO(&newUser), nil
+}
+
+// createUser checks that the user's email and username are free, generating
+// a username from the name when none is given, then stores the user with the
+// hashed password and the named roles.
+func createUser(db *gorm.DB, user *User, password string, roleNames []string) error {
 	// Check if email already exists
+	user.Email = normalizeEmail(user.Email)
 	var existingUser User
-	if err := s.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		return nil, errors.New("email already exists")
+	if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
+		return errors.New("email already exists")
+	}
+
+	username := normalizeUsername(user.Username)
+	if username == "" {
+		generated, err := generateUsername(db, user.Name)
+		if err != nil {
+			return err
+		}
+		username = generated
+	} else if taken, err := usernameTaken(db, username, 0); err != nil {
+		return err
+	} else if taken {
+		return errUsernameTaken
 	}
+	user.Username = username
 
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	user.Password = string(hashedPassword)
 
-	// Create user
-	newUser := User{
-		Name:     req.Name,
-		Email:    req.Email,
-		Password: string(hashedPassword),
+	roles, err := findRoles(db, uniqueStrings(roleNames))
+	if err != nil {
+		return err
 	}
+	user.Roles = roles
 
-	if err := s.db.Create(&newUser).Error; err != nil {
-		return nil, err
+	return db.Create(user).Error
+}
+
+// SeedDefaultUser creates the verified admin 'ikhsan' for an empty database.
+// It bypasses REGISTRATION_MODE, which would otherwise leave nobody able to
+// get in or invite anyone.
+func SeedDefaultUser(db *gorm.DB, password string) error {
+	now := time.Now()
+	seeded := User{
+		Name:            "ikhsan",
+		Email:           "ikhsan@example.com",
+		EmailVerifiedAt: &now, // The seed address cannot receive mail
 	}
+	return createUser(db, &seeded, password, []string{auth.RoleAdmin})
+}
 
-	response := &UserResponseDTO{
-		ID:    newUser.ID,
-		Name:  newUser.Name,
-		Email: newUser.Email,
+// normalizeEmail returns the stored form of an email address. Addresses are
+// stored in lowercase, which makes the unique index case-insensitive.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// MigrateEmails lowercases stored email addresses. Addresses that would then
+// clash with another account are left alone and logged, to be resolved by hand.
+func MigrateEmails(db *gorm.DB) error {
+	var users []User
+	if err := db.Unscoped().Select("id", "email").Where("email <> LOWER(email)").Order("id").Find(&users).Error; err != nil {
+		return err
 	}
 
-	return response, nil
+	for _, user := range users {
+		email := normalizeEmail(user.Email)
+		var count int64
+		if err := db.Model(&User{}).Unscoped().Where("email = ?", email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Cannot lowercase the email of user %d, another account uses %s", user.ID, email)
+			continue
+		}
+		if err := db.Model(&User{}).Unscoped().Where("id = ?", user.ID).UpdateColumn("email", email).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Login authenticates a user and returns a JWT.
-func (s *service) Login(req LoginRequestDTO) (*LoginResponseDTO, error) {
+// Login authenticates a user by email or username and returns an access
+// token and a refresh token. Failed attempts are counted per account and per
+// client IP, and either is locked out for a while after too many. Users with
+// 2FA enabled get a *TwoFactorChallenge error to continue with LoginTwoFactor
+// instead of tokens.
+func (s *service) Login(req LoginRequestDTO, client ClientInfo) (*LoginResponseDTO, error) {
 	var user User
 
 	identifier := req.Identifier
@@ -71,54 +242,118 @@ func (s *service) Login(req LoginRequestDTO) (*LoginResponseDTO, error) {
 		return nil, errors.New("email or username is required")
 	}
 
-	// Search by email OR name
-	if err := s.db.Where("email = ? OR name = ?", identifier, identifier).First(&user).Error; err != nil {
-		return nil, errors.New("invalid credentials")
+	// Usernames cannot contain "@", so the identifier matches at most one user
+	query := s.db.Preload("Roles")
+	if strings.Contains(identifier, "@") {
+		query = query.Where("email = ?", normalizeEmail(identifier))
+	} else {
+		query = query.Where("username = ?", normalizeUsername(identifier))
+	}
+	err := query.First(&user).Error
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Unknown identifiers are counted too, so lockouts do not reveal which accounts exist
+	accountKey := auth.AccountKey(strings.ToLower(identifier))
+	if found {
+		accountKey = accountKeyFor(user.ID)
+	}
+	ipKey := ""
+	if client.IP != "" {
+		ipKey = auth.IPKey(client.IP)
 	}
 
-	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if err := s.throttle.Check(accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
+	// Compare password, against a dummy hash for unknown identifiers so the
+	// response takes as long as for a real account
+	passwordHash := user.Password
+	if !found {
+		passwordHash = dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil || !found {
+		if err := s.throttle.Failure(accountKey, ipKey); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Generate JWT
-	token, err := generateJWT(user)
-	if err != nil {
+	if err := s.throttle.Reset(accountKey); err != nil {
 		return nil, err
 	}
 
-	response := &LoginResponseDTO{
-		Token: token,
-		User: UserResponseDTO{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-		},
+	if user.EmailVerifiedAt == nil && auth.EmailVerificationMode() == auth.VerificationLogin {
+		return nil, errors.New("email address has not been verified")
 	}
 
-	return response, nil
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, s.newTwoFactorChallenge(user.ID)
+	}
+
+	// Generate tokens
+	return s.startSession(user, client)
 }
 
-// generateJWT creates a new JWT for a given user.
-func generateJWT(user User) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
+// dummyPasswordHash is a bcrypt hash at bcrypt.DefaultCost. Login compares
+// against it for unknown identifiers, which fail whatever the result.
+const dummyPasswordHash = "$2a$10$sqIaDjEzjEcBuFeuvAflnOOWl1RrxCTrxcM18JfATojHbTGkwhPem"
 
-	// Set claims
-	claims := jwt.MapClaims{
-		"sub":   strconv.Itoa(int(user.ID)),
-		"email": user.Email,
-		"iat":   time.Now().Unix(),
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
+// UnlockUser clears a user's failed login attempts, lifting any lockout.
+func (s *service) UnlockUser(actor audit.Actor, userID uint) error {
+	if _, err := s.findUser(userID); err != nil {
+		return err
 	}
+	if err := s.throttle.Reset(accountKeyFor(userID)); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, audit.ActionUserUnlock, audit.TargetUser, userID, nil)
+}
+
+// accountKeyFor returns the failed-login counter key for a user.
+func accountKeyFor(userID uint) string {
+	return auth.AccountKey(strconv.Itoa(int(userID)))
+}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// generateJWT creates a new JWT for a given user's session.
+func generateJWT(user User, sessionID string) (string, error) {
+	token, _, err := auth.NewAccessToken(auth.Identity{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Roles:         roleNames(&user),
+		SessionID:     sessionID,
+	}, accessTokenTTL())
+	return token, err
+}
 
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return "", err
+// toResponseDTO converts a User model to UserResponseDTO.
+func (s *service) toResponseDTO(user *User) *UserResponseDTO {
+	response := &UserResponseDTO{
+		ID:                  user.ID,
+		Name:                user.Name,
+		Username:            user.Username,
+		Email:               user.Email,
+		EmailVerified:       user.EmailVerifiedAt != nil,
+		PendingEmail:        user.PendingEmail,
+		TwoFactorEnabled:    user.TOTPEnabledAt != nil,
+		Roles:               roleNames(user),
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		DisabledAt:          user.DisabledAt,
 	}
-
-	return t, nil
+	if user.AvatarKey != "" {
+		response.AvatarURLs = make(map[string]string, len(avatarSizes))
+		for _, size := range avatarSizes {
+			response.AvatarURLs[strconv.Itoa(size)] = s.files.URL(avatarFileKey(user.AvatarKey, size))
+		}
+		response.AvatarURL = response.AvatarURLs[strconv.Itoa(avatarSizes[len(avatarSizes)-1])]
+	}
+	return response
 }
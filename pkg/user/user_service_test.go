@@ -0,0 +1,63 @@
+package user
+
+import (
+	"tasklybe/pkg/audit"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/filter"
+	"tasklybe/pkg/mailer"
+	"tasklybe/pkg/planner"
+	"tasklybe/pkg/task"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPassword is the password of users made by createTestUser.
+const testPassword = "correct horse battery"
+
+// newTestService returns a service on an in-memory database, signing tokens
+// with a JWT_SECRET key set.
+func newTestService(t *testing.T, providers map[string]OIDCProvider) *service {
+	t.Helper()
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("JWT_SECRET", "test-secret")
+	keys, err := auth.LoadKeySetFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.UseKeySet(keys)
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}, &Role{}, &RefreshToken{}, &ActionToken{}, &OAuthState{}, &ExternalIdentity{}, &Session{}, &Preferences{}, &RecoveryCode{}, &auth.RevokedToken{}, &auth.UserRevocation{}, &auth.LoginAttempt{}, &APIToken{}, &audit.Entry{}, &task.Task{}, &filter.SavedFilter{}, &planner.PlanItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SeedRoles(db); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := auth.NewMemoryAttemptStore()
+	return NewService(db, auth.NewRevocationStore(db), mailer.NewLogMailer(), auth.NewLoginThrottle(attempts), auth.NewEmailThrottle(attempts),
+		nil, nil, providers).(*service)
+}
+
+// createTestUser stores a user with the given email, verified or not.
+func createTestUser(t *testing.T, s *service, email string, verified bool) *User {
+	t.Helper()
+	user := &User{Name: "Budi Santoso", Email: email}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := createUser(s.db, user, testPassword, []string{auth.RoleStudent}); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
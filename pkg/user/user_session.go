@@ -0,0 +1,153 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxUserAgentLength matches the size of Session.UserAgent.
+const maxUserAgentLength = 255
+
+// ClientInfo describes the device a request comes from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// startSession records a new login session and issues its first tokens.
+// Every way of signing in ends here, so disabled accounts are refused here.
+func (s *service) startSession(user User, client ClientInfo) (*LoginResponseDTO, error) {
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	familyID, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		UserAgent:  truncate(client.UserAgent, maxUserAgentLength),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	}
+	var response *LoginResponseDTO
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		response, err = s.issueTokens(tx, user, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// touchSession records that the session of a refresh token is still in use
+// and returns it. Families issued before sessions existed get one now.
+func touchSession(tx *gorm.DB, token RefreshToken, client ClientInfo) (*Session, error) {
+	now := time.Now()
+	var session Session
+	err := tx.Where("family_id = ?", token.FamilyID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session = Session{UserID: token.UserID, FamilyID: token.FamilyID, CreatedAt: token.CreatedAt}
+	} else if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, errors.New("session has been ended")
+	}
+
+	session.UserAgent = truncate(client.UserAgent, maxUserAgentLength)
+	session.IP = client.IP
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL())
+	if err := tx.Save(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListSessions lists the user's active sessions, most recently used first.
+func (s *service) ListSessions(userID uint, currentSessionID string) ([]SessionResponseDTO, error) {
+	var sessions []Session
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	response := make([]SessionResponseDTO, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponseDTO{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    strconv.Itoa(int(session.ID)) == currentSessionID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+	return response, nil
+}
+
+// RevokeSession ends one of the user's sessions, logging that device out.
+func (s *service) RevokeSession(userID, sessionID uint) error {
+	var count int64
+	if err := s.db.Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("session not found")
+	}
+	return s.endSessions("id = ? AND user_id = ?", sessionID, userID)
+}
+
+// endSessions ends the active sessions matching the condition: their refresh
+// tokens are revoked, and so are access tokens already issued to them.
+func (s *service) endSessions(query string, args ...interface{}) error {
+	var sessions []Session
+	if err := s.db.Where(query, args...).Where("revoked_at IS NULL").Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, session := range sessions {
+		if err := s.db.Model(&session).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := s.revokeRefreshTokens("family_id = ?", session.FamilyID); err != nil {
+			return err
+		}
+		// Access tokens issued to the session expire within one lifetime
+		sessionID := strconv.Itoa(int(session.ID))
+		if err := s.revocations.RevokeSession(sessionID, session.UserID, now.Add(accessTokenTTL())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate drops invalid UTF-8 from s and shortens it to at most max
+// characters, which is how varchar sizes are counted.
+func truncate(s string, max int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max])
+}
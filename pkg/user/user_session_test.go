@@ -0,0 +1,33 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"short", "curl/8.0", 255, "curl/8.0"},
+		{"ascii", "abcdef", 4, "abcd"},
+		{"counts characters, not bytes", "ブラウザ/1.0", 4, "ブラウザ"},
+		{"never splits a character", "aéb", 2, "aé"},
+		{"drops invalid bytes", "Mozilla\xff/5.0", 255, "Mozilla/5.0"},
+		{"drops invalid bytes before counting", "\xff\xfeabc", 3, "abc"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.max); got != tt.want {
+			t.Errorf("%s: truncate(%q, %d) = %q, want %q", tt.name, tt.in, tt.max, got, tt.want)
+		}
+	}
+
+	long := truncate(strings.Repeat("é", 300), maxUserAgentLength)
+	if !utf8.ValidString(long) || utf8.RuneCountInString(long) != maxUserAgentLength {
+		t.Errorf("long user agent truncated to %d characters, valid UTF-8: %v", utf8.RuneCountInString(long), utf8.ValidString(long))
+	}
+}
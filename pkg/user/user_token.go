@@ -0,0 +1,265 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"tasklybe/pkg/auth"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// accessTokenTTL returns the access token lifetime from JWT_EXPIRES_IN.
+func accessTokenTTL() time.Duration {
+	return durationFromEnv("JWT_EXPIRES_IN", defaultAccessTokenTTL)
+}
+
+// refreshTokenTTL returns the refresh token lifetime from JWT_REFRESH_EXPIRES_IN.
+func refreshTokenTTL() time.Duration {
+	return durationFromEnv("JWT_REFRESH_EXPIRES_IN", defaultRefreshTokenTTL)
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// newOpaqueToken returns a random, URL-safe token with 256 bits of entropy.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token. Opaque tokens are random
+// enough that a fast hash is sufficient, unlike passwords.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createActionToken invalidates the user's unused tokens for the purpose and
+// returns a new one that expires after ttl.
+func (s *service) createActionToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&ActionToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ActionToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeActionToken marks a token as used and returns it. It fails if the
+// token is unknown, expired, already used or issued for another purpose.
+func (s *service) consumeActionToken(tx *gorm.DB, token, purpose string) (*ActionToken, error) {
+	var record ActionToken
+	err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	// Guard against the same token being used by two concurrent requests
+	result := tx.Model(&ActionToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid or expired token")
+	}
+	return &record, nil
+}
+
+// errRefreshTokenReused reports a refresh token rotated by another request.
+var errRefreshTokenReused = errors.New("refresh token was already rotated")
+
+// issueTokens creates an access token and a refresh token for the user's
+// session, continuing the session's refresh token family.
+func (s *service) issueTokens(tx *gorm.DB, user User, session *Session) (*LoginResponseDTO, error) {
+	accessToken, err := generateJWT(user, strconv.Itoa(int(session.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.FamilyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	response := &LoginResponseDTO{
+		Token:        accessToken,
+		ExpiresIn:    int64(accessTokenTTL().Seconds()),
+		RefreshToken: refreshToken,
+		User:         *s.toResponseDTO(&user),
+	}
+	return response, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// Each refresh token can be used once; presenting a token that was already
+// rotated is treated as theft and revokes every token in its family.
+func (s *service) Refresh(req RefreshRequestDTO, client ClientInfo) (*LoginResponseDTO, error) {
+	var token RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid refresh token")
+		}
+		return nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return nil, errors.New("refresh token has been revoked")
+	}
+	if token.RotatedAt != nil {
+		return nil, s.revokeFamilyOnReuse(token)
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, errors.New("refresh token has expired")
+	}
+
+	var user User
+	if err := s.db.Preload("Roles").First(&user, token.UserID).Error; err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	// Rotate, touch the session and issue the new pair together, so a failure
+	// leaves the presented token usable for a retry
+	var response *LoginResponseDTO
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Mark as rotated only if no concurrent request got there first
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", token.ID).
+			Update("rotated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		session, err := touchSession(tx, token, client)
+		if err != nil {
+			return err
+		}
+		response, err = s.issueTokens(tx, user, session)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		return nil, s.revokeFamilyOnReuse(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// revokeFamilyOnReuse revokes every token in the family of a reused token.
+func (s *service) revokeFamilyOnReuse(token RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking its session", token.UserID)
+	if err := s.revokeRefreshTokens("family_id = ?", token.FamilyID); err != nil {
+		return err
+	}
+	if err := s.endSessions("family_id = ?", token.FamilyID); err != nil {
+		return err
+	}
+	return errors.New("refresh token reuse detected, please log in again")
+}
+
+// revokeRefreshTokens revokes all unrevoked refresh tokens matching the condition.
+func (s *service) revokeRefreshTokens(query string, args ...interface{}) error {
+	return s.db.Model(&RefreshToken{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+// Logout revokes the access token used for the request and ends its
+// session. For tokens issued before sessions existed, the refresh token
+// family is revoked when the refresh token is given.
+func (s *service) Logout(userID uint, claims *auth.AccessClaims, req LogoutRequestDTO) error {
+	if sessionID, err := strconv.ParseUint(claims.SessionID, 10, 32); err == nil {
+		if err := s.endSessions("id = ? AND user_id = ?", uint(sessionID), userID); err != nil {
+			return err
+		}
+	} else if req.RefreshToken != "" {
+		var token RefreshToken
+		err := s.db.Where("token_hash = ? AND user_id = ?", hashToken(req.RefreshToken), userID).First(&token).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if err := s.revokeRefreshTokens("family_id = ?", token.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.revocations.RevokeToken(claims.ID, userID, claims.ExpiresAt.Time)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user.
+func (s *service) LogoutAll(userID uint) error {
+	if err := s.revokeRefreshTokens("user_id = ?", userID); err != nil {
+		return err
+	}
+	if err := s.endSessions("user_id = ?", userID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeAllForUser(userID)
+}
@@ -0,0 +1,64 @@
+package user
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// login logs the user in with the test password.
+func login(t *testing.T, s *service, user *User) *LoginResponseDTO {
+	t.Helper()
+	response, err := s.Login(LoginRequestDTO{Identifier: user.Email, Password: testPassword}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	first := login(t, s, user)
+
+	second, err := s.Refresh(RefreshRequestDTO{RefreshToken: first.RefreshToken}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+
+	// Presenting the rotated token again ends the session
+	if _, err := s.Refresh(RefreshRequestDTO{RefreshToken: first.RefreshToken}, ClientInfo{}); err == nil {
+		t.Fatal("a rotated refresh token was accepted")
+	}
+	if _, err := s.Refresh(RefreshRequestDTO{RefreshToken: second.RefreshToken}, ClientInfo{}); err == nil {
+		t.Fatal("the session survived refresh token reuse")
+	}
+}
+
+func TestRefreshFailureKeepsTokenUsable(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	first := login(t, s, user)
+
+	// Fail the insert of the new refresh token once
+	failed := false
+	if err := s.db.Callback().Create().Before("gorm:create").Register("test:fail_refresh_token", func(db *gorm.DB) {
+		if db.Statement.Table == "refresh_tokens" && !failed {
+			failed = true
+			db.AddError(errors.New("connection reset"))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Refresh(RefreshRequestDTO{RefreshToken: first.RefreshToken}, ClientInfo{}); err == nil {
+		t.Fatal("refresh succeeded despite the failed insert")
+	}
+	if _, err := s.Refresh(RefreshRequestDTO{RefreshToken: first.RefreshToken}, ClientInfo{}); err != nil {
+		t.Fatalf("retry after a failed refresh: %v", err)
+	}
+}
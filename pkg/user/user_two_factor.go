@@ -0,0 +1,300 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"tasklybe/pkg/auth"
+	"tasklybe/pkg/totp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+// TwoFactorChallenge is returned by Login when the password was correct but
+// the user has 2FA enabled. The challenge token is exchanged, together with a
+// code, for tokens via LoginTwoFactor.
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+func (c *TwoFactorChallenge) Error() string {
+	return "two-factor authentication required"
+}
+
+// totpIssuer returns the name shown in authenticator apps, from TOTP_ISSUER.
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Taskly"
+}
+
+// newTwoFactorChallenge returns a *TwoFactorChallenge for the user, or the
+// error that prevented creating one.
+func (s *service) newTwoFactorChallenge(userID uint) error {
+	token, err := s.createActionToken(userID, TokenPurposeTwoFactor, twoFactorChallengeTTL)
+	if err != nil {
+		return err
+	}
+	return &TwoFactorChallenge{Token: token, ExpiresIn: twoFactorChallengeTTL}
+}
+
+// LoginTwoFactor completes a login started with Login, using a TOTP code or
+// a recovery code. Wrong codes count towards the account lockout.
+func (s *service) LoginTwoFactor(req LoginTwoFactorRequestDTO, client ClientInfo) (*LoginResponseDTO, error) {
+	var challenge ActionToken
+	err := s.db.Where("token_hash = ? AND purpose = ?", hashToken(req.ChallengeToken), TokenPurposeTwoFactor).
+		First(&challenge).Error
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.New("invalid or expired challenge, please log in again")
+	}
+
+	accountKey := accountKeyFor(challenge.UserID)
+	ipKey := ""
+	if client.IP != "" {
+		ipKey = auth.IPKey(client.IP)
+	}
+	if err := s.throttle.Check(accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
+	user, err := s.findUser(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.verifySecondFactor(user, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.throttle.Failure(accountKey, ipKey); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid code")
+	}
+
+	if _, err := s.consumeActionToken(s.db, req.ChallengeToken, TokenPurposeTwoFactor); err != nil {
+		return nil, errors.New("invalid or expired challenge, please log in again")
+	}
+	if err := s.throttle.Reset(accountKey); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(*user, client)
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user. 2FA is not
+// enforced until the secret is confirmed with ConfirmTwoFactor.
+func (s *service) EnrollTwoFactor(userID uint) (*TwoFactorEnrollmentDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollmentDTO{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer(), user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator app
+// works, and returns the first set of recovery codes.
+func (s *service) ConfirmTwoFactor(userID uint, req TwoFactorCodeRequestDTO) (*RecoveryCodesDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("start two-factor enrollment first")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesDTO{Codes: codes}, nil
+}
+
+// DisableTwoFactor turns 2FA off. It requires both the password and a
+// current code, so a stolen session alone cannot weaken the account.
+func (s *service) DisableTwoFactor(userID uint, req DisableTwoFactorRequestDTO) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return errors.New("password is incorrect")
+	}
+
+	ok, err := s.verifySecondFactor(user, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid code")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, invalidating
+// the old ones.
+func (s *service) RegenerateRecoveryCodes(userID uint, req TwoFactorCodeRequestDTO) (*RecoveryCodesDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	ok, err := s.verifySecondFactor(user, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesDTO{Codes: codes}, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, an unused recovery
+// code. Accepted codes cannot be used again.
+func (s *service) verifySecondFactor(user *User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		// Only accept each time step once, even across concurrent requests
+		result := s.db.Model(&User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+
+	var recoveryCodes []RecoveryCode
+	if err := s.db.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&recoveryCodes).Error; err != nil {
+		return false, err
+	}
+	for _, recoveryCode := range recoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(recoveryCode.CodeHash), []byte(normalized)) != nil {
+			continue
+		}
+		result := s.db.Model(&RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", recoveryCode.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and creates new
+// ones, returning them in plain text for display.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&RecoveryCode{UserID: userID, CodeHash: string(hash)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a random code formatted like "abcde-fghij".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode lowercases a code and strips separators so users
+// can type it loosely.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
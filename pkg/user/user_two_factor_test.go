@@ -0,0 +1,188 @@
+package user
+
+import (
+	"errors"
+	"tasklybe/pkg/totp"
+	"testing"
+	"time"
+)
+
+// enableTwoFactor turns 2FA on for the user. It returns the secret, the
+// recovery codes and the time step whose code confirmed enrollment.
+func enableTwoFactor(t *testing.T, s *service, user *User) (string, []string, int64) {
+	t.Helper()
+	enrollment, err := s.EnrollTwoFactor(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	recovery, err := s.ConfirmTwoFactor(user.ID, TwoFactorCodeRequestDTO{Code: codeAt(t, enrollment.Secret, step)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enrollment.Secret, recovery.Codes, step
+}
+
+// codeAt returns the TOTP code for a time step.
+func codeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// startLogin logs in with the password and returns the 2FA challenge token.
+func startLogin(t *testing.T, s *service, user *User) string {
+	t.Helper()
+	_, err := s.Login(LoginRequestDTO{Identifier: user.Email, Password: testPassword}, ClientInfo{})
+	var challenge *TwoFactorChallenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("got %v, want a two-factor challenge", err)
+	}
+	return challenge.Token
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	secret, _, step := enableTwoFactor(t, s, user)
+
+	challenge := startLogin(t, s, user)
+	if _, err := s.LoginTwoFactor(LoginTwoFactorRequestDTO{ChallengeToken: challenge, Code: "000000"}, ClientInfo{}); err == nil {
+		t.Fatal("a wrong code was accepted")
+	}
+
+	// The wrong code did not use up the challenge. Confirming used up the
+	// enrollment step, so log in with the next one
+	req := LoginTwoFactorRequestDTO{ChallengeToken: challenge, Code: codeAt(t, secret, step+1)}
+	login, err := s.LoginTwoFactor(req, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.User.ID != user.ID || login.Token == "" || login.RefreshToken == "" {
+		t.Fatalf("unexpected login: %+v", login)
+	}
+
+	if _, err := s.LoginTwoFactor(req, ClientInfo{}); err == nil {
+		t.Fatal("a used challenge was accepted again")
+	}
+}
+
+func TestLoginTwoFactorRejectsInvalidChallenge(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	secret, _, step := enableTwoFactor(t, s, user)
+
+	req := LoginTwoFactorRequestDTO{ChallengeToken: "not-a-challenge", Code: codeAt(t, secret, step+1)}
+	if _, err := s.LoginTwoFactor(req, ClientInfo{}); err == nil {
+		t.Fatal("an unknown challenge was accepted")
+	}
+
+	req.ChallengeToken = startLogin(t, s, user)
+	if err := s.db.Model(&ActionToken{}).Where("purpose = ?", TokenPurposeTwoFactor).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoginTwoFactor(req, ClientInfo{}); err == nil {
+		t.Fatal("an expired challenge was accepted")
+	}
+}
+
+func TestVerifySecondFactorRejectsReplayedCode(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	secret, _, step := enableTwoFactor(t, s, user)
+
+	tests := []struct {
+		name string
+		step int64
+		want bool
+	}{
+		{"step used to confirm enrollment", step, false},
+		{"next step", step + 1, true},
+		{"same step again", step + 1, false},
+		{"step before the last one used", step, false},
+		{"too far ahead", step + 3, false},
+	}
+	for _, tt := range tests {
+		current, err := s.findUser(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := s.verifySecondFactor(current, codeAt(t, secret, tt.step))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: verifySecondFactor = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	_, codes, _ := enableTwoFactor(t, s, user)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Codes can be typed loosely
+	loose := " " + codes[0][:5] + " " + codes[0][6:] + " "
+	if _, err := s.LoginTwoFactor(LoginTwoFactorRequestDTO{ChallengeToken: startLogin(t, s, user), Code: loose}, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoginTwoFactor(LoginTwoFactorRequestDTO{ChallengeToken: startLogin(t, s, user), Code: codes[0]}, ClientInfo{}); err == nil {
+		t.Fatal("a used recovery code was accepted again")
+	}
+	if _, err := s.LoginTwoFactor(LoginTwoFactorRequestDTO{ChallengeToken: startLogin(t, s, user), Code: codes[1]}, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	s := newTestService(t, nil)
+	user := createTestUser(t, s, "budi@school.id", true)
+	secret, codes, step := enableTwoFactor(t, s, user)
+
+	tests := []struct {
+		name    string
+		req     DisableTwoFactorRequestDTO
+		wantErr string
+	}{
+		{"wrong password", DisableTwoFactorRequestDTO{Password: "wrong password", Code: codeAt(t, secret, step+1)}, "password is incorrect"},
+		{"wrong code", DisableTwoFactorRequestDTO{Password: testPassword, Code: "000000"}, "invalid code"},
+		{"replayed code", DisableTwoFactorRequestDTO{Password: testPassword, Code: codeAt(t, secret, step)}, "invalid code"},
+	}
+	for _, tt := range tests {
+		if err := s.DisableTwoFactor(user.ID, tt.req); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if err := s.DisableTwoFactor(user.ID, DisableTwoFactorRequestDTO{Password: testPassword, Code: codes[0]}); err != nil {
+		t.Fatal(err)
+	}
+	disabled, err := s.findUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.TOTPEnabledAt != nil || disabled.TOTPSecret != "" || disabled.TOTPLastStep != 0 {
+		t.Fatalf("2FA is still set up: %+v", disabled)
+	}
+	var remaining int64
+	s.db.Model(&RecoveryCode{}).Where("user_id = ?", user.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("%d recovery codes remain", remaining)
+	}
+
+	// Logging in no longer asks for a code
+	if _, err := s.Login(LoginRequestDTO{Identifier: user.Email, Password: testPassword}, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisableTwoFactor(user.ID, DisableTwoFactorRequestDTO{Password: testPassword, Code: codeAt(t, secret, step+1)}); err == nil {
+		t.Fatal("disabling 2FA twice succeeded")
+	}
+}
@@ -0,0 +1,91 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"tasklybe/pkg/validation"
+
+	"gorm.io/gorm"
+)
+
+const maxUsernameLength = 30
+
+// errUsernameTaken is returned when a requested username belongs to someone else.
+var errUsernameTaken = errors.New("username already exists")
+
+// normalizeUsername returns the stored form of a username. Usernames are
+// stored in lowercase, which makes the unique index case-insensitive.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// usernameTaken reports whether another user already has the username.
+func usernameTaken(db *gorm.DB, username string, exceptUserID uint) (bool, error) {
+	var count int64
+	err := db.Model(&User{}).Unscoped().
+		Where("username = ? AND id != ?", normalizeUsername(username), exceptUserID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// generateUsername derives a free username from a display name, e.g.
+// "Budi Santoso" becomes "budi_santoso", then "budi_santoso2" if taken.
+func generateUsername(db *gorm.DB, name string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_':
+			b.WriteRune(r)
+		case r == ' ' || r == '-':
+			b.WriteRune('_')
+		}
+	}
+	base := strings.Trim(b.String(), "._")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	for suffix := 1; ; suffix++ {
+		tail := ""
+		if suffix > 1 {
+			tail = strconv.Itoa(suffix)
+		}
+		head := base
+		if len(head)+len(tail) > maxUsernameLength {
+			head = strings.TrimRight(head[:maxUsernameLength-len(tail)], "._")
+		}
+		candidate := head + tail
+		if !validation.IsValidUsername(candidate) {
+			continue
+		}
+
+		taken, err := usernameTaken(db, candidate, 0)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+}
+
+// MigrateUsernames gives every user without a username one generated from
+// their name.
+func MigrateUsernames(db *gorm.DB) error {
+	var users []User
+	if err := db.Unscoped().Where("username IS NULL OR username = ''").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		username, err := generateUsername(db, user.Name)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&User{}).Unscoped().Where("id = ?", user.ID).UpdateColumn("username", username).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultEmailVerificationTTL = 48 * time.Hour
+
+// sendVerificationEmail emails the user a link to confirm their address.
+// Failures are logged rather than returned, since the user can ask for
+// another email later.
+func (s *service) sendVerificationEmail(user User) {
+	ttl := durationFromEnv("EMAIL_VERIFICATION_EXPIRES_IN", defaultEmailVerificationTTL)
+	token, err := s.createActionToken(user.ID, TokenPurposeEmailVerification, ttl)
+	if err != nil {
+		log.Printf("Failed to create email verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := appURL() + "/verify-email?token=" + token
+	msg := s.composeEmail(user, emailVerification, link, time.Now().Add(ttl))
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+// sendEmailChangeConfirmation emails the user's pending address a link to
+// confirm it. Sending a new link invalidates earlier ones, so a link sent to
+// a previous pending address cannot confirm the current one. Failures are
+// logged, since the user can ask for the change again.
+func (s *service) sendEmailChangeConfirmation(user User) {
+	ttl := durationFromEnv("EMAIL_VERIFICATION_EXPIRES_IN", defaultEmailVerificationTTL)
+	token, err := s.createActionToken(user.ID, TokenPurposeEmailChange, ttl)
+	if err != nil {
+		log.Printf("Failed to create email change token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := appURL() + "/confirm-email?token=" + token
+	msg := s.composeEmail(user, emailChange, link, time.Now().Add(ttl))
+	msg.To = user.PendingEmail
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send email change confirmation to user %d: %v", user.ID, err)
+	}
+}
+
+// ConfirmEmailChange replaces the user's email address with the pending one
+// using the token emailed to it. The new address counts as verified.
+func (s *service) ConfirmEmailChange(req VerifyEmailRequestDTO) (*UserResponseDTO, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := s.consumeActionToken(tx, req.Token, TokenPurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Roles").First(&user, token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invalid or expired token")
+			}
+			return err
+		}
+		if user.PendingEmail == "" {
+			return errors.New("invalid or expired token")
+		}
+
+		// Someone may have registered the address since the change was requested
+		var existingUser User
+		if err := tx.Where("email = ? AND id != ?", user.PendingEmail, user.ID).First(&existingUser).Error; err == nil {
+			return errors.New("email already exists")
+		}
+
+		now := time.Now()
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":             user.Email,
+			"pending_email":     "",
+			"email_verified_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toResponseDTO(&user), nil
+}
+
+// VerifyEmail marks the user's email address as verified using an emailed token.
+func (s *service) VerifyEmail(req VerifyEmailRequestDTO) (*UserResponseDTO, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := s.consumeActionToken(tx, req.Token, TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Roles").First(&user, token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invalid or expired token")
+			}
+			return err
+		}
+
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toResponseDTO(&user), nil
+}
+
+// ResendVerification sends a new verification email if the address belongs
+// to an unverified user. Like ForgotPassword, it is rate limited, padded to
+// a minimum duration and quiet about unknown addresses, so callers cannot
+// tell which accounts exist.
+func (s *service) ResendVerification(req ResendVerificationRequestDTO, client ClientInfo) error {
+	defer padEmailRequest(time.Now())
+
+	email := normalizeEmail(req.Email)
+	if err := s.limitEmailRequest(email, client.IP); err != nil {
+		return err
+	}
+
+	var user User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		s.sendVerificationEmail(user)
+	}
+	return nil
+}
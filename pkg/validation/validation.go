@@ -2,13 +2,57 @@ package validation
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"tasklybe/pkg/password"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+// usernamePattern allows 3-30 letters, digits, dots and underscores,
+// starting and ending with a letter or digit. It never matches an email
+// address, so a login identifier containing "@" is always an email.
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9._]{1,28})[a-zA-Z0-9]$`)
+
+// PersonalDetails is implemented by requests that carry details a new
+// password must not contain, such as the user's name and email address.
+type PersonalDetails interface {
+	PersonalDetails() []string
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return IsValidUsername(fl.Field().String())
+	})
+
+	// "password" applies the password policy; failures report the rule broken
+	v.RegisterValidation(password.RuleLength, func(fl validator.FieldLevel) bool {
+		return password.PolicyFromEnv().LengthOK(fl.Field().String())
+	})
+	v.RegisterValidation(password.RuleClasses, func(fl validator.FieldLevel) bool {
+		return password.Classes(fl.Field().String()) >= password.PolicyFromEnv().MinClasses
+	})
+	v.RegisterValidation(password.RulePersonal, func(fl validator.FieldLevel) bool {
+		details, ok := fl.Parent().Interface().(PersonalDetails)
+		return !ok || !password.ContainsPersonal(fl.Field().String(), details.PersonalDetails()...)
+	})
+	v.RegisterValidation(password.RuleCommon, func(fl validator.FieldLevel) bool {
+		return !password.PolicyFromEnv().RejectCommon || !password.IsCommon(fl.Field().String())
+	})
+	v.RegisterAlias("password", strings.Join([]string{
+		password.RuleLength, password.RuleClasses, password.RulePersonal, password.RuleCommon,
+	}, ","))
+	return v
+}
+
+// IsValidUsername reports whether s is a well-formed username.
+func IsValidUsername(s string) bool {
+	return usernamePattern.MatchString(s)
+}
 
 // BindAndValidate binds the request body to a struct and validates it.
 func BindAndValidate(c *fiber.Ctx, dto interface{}) (bool, []string) {
@@ -23,6 +67,19 @@ func BindAndValidate(c *fiber.Ctx, dto interface{}) (bool, []string) {
 	return true, nil
 }
 
+// BindQueryAndValidate binds the query string to a struct and validates it.
+func BindQueryAndValidate(c *fiber.Ctx, dto interface{}) (bool, []string) {
+	if err := c.QueryParser(dto); err != nil {
+		return false, []string{"Failed to parse query parameters: " + err.Error()}
+	}
+
+	if err := validate.Struct(dto); err != nil {
+		return false, FormatValidationErrors(err)
+	}
+
+	return true, nil
+}
+
 // FormatValidationErrors formats validation errors into a readable slice of strings.
 func FormatValidationErrors(err error) []string {
 	var errors []string
@@ -38,13 +95,28 @@ func FormatValidationErrors(err error) []string {
 
 func formatError(err validator.FieldError) string {
 	field := strings.ToLower(err.Field())
-	switch err.Tag() {
+	// Aliases such as "password" report the rule that failed as ActualTag
+	switch err.ActualTag() {
 	case "required":
 		return fmt.Sprintf("%s is required", field)
 	case "min":
 		return fmt.Sprintf("%s must be at least %s characters long", field, err.Param())
 	case "email":
 		return fmt.Sprintf("%s must be a valid email address", field)
+	case "datetime":
+		return fmt.Sprintf("%s must match the format %s", field, err.Param())
+	case "timezone":
+		return fmt.Sprintf("%s must be an IANA time zone such as Asia/Jakarta", field)
+	case "username":
+		return fmt.Sprintf("%s must be 3-30 letters, digits, dots or underscores, starting and ending with a letter or digit", field)
+	case password.RuleLength:
+		return fmt.Sprintf("%s must be at least %d characters and at most %d bytes long", field, password.PolicyFromEnv().MinLength, password.MaxBytes)
+	case password.RuleClasses:
+		return fmt.Sprintf("%s must use at least %d of: lowercase letters, uppercase letters, digits and symbols", field, password.PolicyFromEnv().MinClasses)
+	case password.RulePersonal:
+		return fmt.Sprintf("%s must not contain your name, username or email address", field)
+	case password.RuleCommon:
+		return fmt.Sprintf("%s is too common and easy to guess, choose another", field)
 	case "oneof":
 		return fmt.Sprintf("%s must be one of [%s]", field, err.Param())
 	default: